@@ -0,0 +1,349 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/mailer"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// emailVerifyWindow is how long the link sent to a new address works.
+	emailVerifyWindow = 24 * time.Hour
+	// emailRevertWindow is how long the old address can undo a change.
+	emailRevertWindow = 7 * 24 * time.Hour
+)
+
+// ChangePassword replaces the caller's password after checking the current
+// one, and signs out every other session.
+func ChangePassword(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request: current password and a new password of at least 8 characters are required",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	user, ok := checkCurrentPassword(ctx, c, userID, req.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error hashing password",
+			Data:         nil,
+		})
+		return
+	}
+
+	_, err = utils.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"password": string(hash)}},
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to change password of user %s: %v", user.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to change password",
+			Data:         nil,
+		})
+		return
+	}
+
+	if err := utils.RevokeSessions(ctx, user.ID, c.GetString("sessionID")); err != nil {
+		log.Printf("[ERROR] Failed to revoke sessions of user %s: %v", user.ID.Hex(), err)
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Password changed successfully",
+		Data:         nil,
+	})
+}
+
+// ChangeEmail starts moving the caller's account to a new address. The new
+// address must be verified, and the old one is told and can revert.
+func ChangeEmail(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request: a valid new email and the current password are required",
+			Data:         nil,
+		})
+		return
+	}
+	newEmail := strings.TrimSpace(req.NewEmail)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := checkCurrentPassword(ctx, c, userID, req.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	if newEmail == user.Email {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "That is already your email address",
+			Data:         nil,
+		})
+		return
+	}
+	existingCount, _ := utils.DB.Collection("users").CountDocuments(ctx, bson.M{"email": newEmail})
+	if existingCount > 0 {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Email already registered",
+			Data:         nil,
+		})
+		return
+	}
+
+	verifyToken, err := randomToken()
+	if err != nil {
+		emailChangeError(c, err)
+		return
+	}
+	revertToken, err := randomToken()
+	if err != nil {
+		emailChangeError(c, err)
+		return
+	}
+
+	now := time.Now()
+	change := models.EmailChange{
+		ID:              primitive.NewObjectID(),
+		UserID:          user.ID,
+		OldEmail:        user.Email,
+		NewEmail:        newEmail,
+		VerifyTokenHash: hashToken(verifyToken),
+		RevertTokenHash: hashToken(revertToken),
+		Status:          models.EmailChangePending,
+		CreatedAt:       now,
+		VerifyExpiresAt: now.Add(emailVerifyWindow),
+		RevertExpiresAt: now.Add(emailRevertWindow),
+	}
+
+	// A new request replaces any earlier one still waiting for verification
+	_, err = utils.DB.Collection("email_changes").DeleteMany(ctx, bson.M{
+		"userId": user.ID,
+		"status": models.EmailChangePending,
+	})
+	if err != nil {
+		emailChangeError(c, err)
+		return
+	}
+	if _, err := utils.DB.Collection("email_changes").InsertOne(ctx, change); err != nil {
+		emailChangeError(c, err)
+		return
+	}
+
+	if err := sendTemplate(ctx, newEmail, "Confirm your new email address", "email_change_verify", gin.H{
+		"Username":  user.Username,
+		"NewEmail":  newEmail,
+		"VerifyURL": appBaseURL() + "/email/verify?token=" + url.QueryEscape(verifyToken),
+		"ExpiresIn": "24 hours",
+	}); err != nil {
+		emailChangeError(c, err)
+		return
+	}
+	if err := sendTemplate(ctx, user.Email, "Your email address is being changed", "email_change_notice", gin.H{
+		"Username":  user.Username,
+		"OldEmail":  user.Email,
+		"NewEmail":  newEmail,
+		"RevertURL": appBaseURL() + "/email/revert?token=" + url.QueryEscape(revertToken),
+		"ExpiresIn": "7 days",
+	}); err != nil {
+		log.Printf("[ERROR] Failed to notify old address of user %s: %v", user.ID.Hex(), err)
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Check your new email address to confirm the change",
+		Data:         nil,
+	})
+}
+
+// VerifyEmailChange completes an email change from the link sent to the new
+// address.
+func VerifyEmailChange(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var change models.EmailChange
+	err := utils.DB.Collection("email_changes").FindOne(ctx, bson.M{
+		"verifyTokenHash": hashToken(c.Query("token")),
+		"status":          models.EmailChangePending,
+		"verifyExpiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&change)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid or expired link",
+			Data:         nil,
+		})
+		return
+	}
+
+	// The address may have been taken since the request
+	existingCount, _ := utils.DB.Collection("users").CountDocuments(ctx, bson.M{"email": change.NewEmail})
+	if existingCount > 0 {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Email already registered",
+			Data:         nil,
+		})
+		return
+	}
+
+	if err := completeEmailChange(ctx, change, change.NewEmail, models.EmailChangeVerified); err != nil {
+		emailChangeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Email address changed successfully",
+		Data:         nil,
+	})
+}
+
+// RevertEmailChange undoes an email change from the link sent to the old
+// address, and signs out every session in case the account was taken over.
+func RevertEmailChange(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var change models.EmailChange
+	err := utils.DB.Collection("email_changes").FindOne(ctx, bson.M{
+		"revertTokenHash": hashToken(c.Query("token")),
+		"status":          bson.M{"$in": []string{models.EmailChangePending, models.EmailChangeVerified}},
+		"revertExpiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&change)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid or expired link",
+			Data:         nil,
+		})
+		return
+	}
+
+	if err := completeEmailChange(ctx, change, change.OldEmail, models.EmailChangeReverted); err != nil {
+		emailChangeError(c, err)
+		return
+	}
+	if err := utils.RevokeSessions(ctx, change.UserID, ""); err != nil {
+		log.Printf("[ERROR] Failed to revoke sessions of user %s: %v", change.UserID.Hex(), err)
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Email change reverted. Please log in again and change your password",
+		Data:         nil,
+	})
+}
+
+func completeEmailChange(ctx context.Context, change models.EmailChange, email, status string) error {
+	_, err := utils.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": change.UserID},
+		bson.M{"$set": bson.M{"email": email}},
+	)
+	if err != nil {
+		return err
+	}
+
+	_, err = utils.DB.Collection("email_changes").UpdateOne(ctx,
+		bson.M{"_id": change.ID},
+		bson.M{"$set": bson.M{"status": status, "completedAt": time.Now()}},
+	)
+	return err
+}
+
+// checkCurrentPassword loads the user and confirms the password they gave,
+// answering the request itself when it doesn't match.
+func checkCurrentPassword(ctx context.Context, c *gin.Context, userID primitive.ObjectID, password string) (models.User, bool) {
+	var user models.User
+	if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "User not found",
+			Data:         nil,
+		})
+		return user, false
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{
+			ResponseCode: http.StatusUnauthorized,
+			Message:      "Current password is incorrect",
+			Data:         nil,
+		})
+		return user, false
+	}
+	return user, true
+}
+
+func emailChangeError(c *gin.Context, err error) {
+	log.Printf("[ERROR] Email change failed: %v", err)
+	c.JSON(http.StatusInternalServerError, models.Response{
+		ResponseCode: http.StatusInternalServerError,
+		Message:      "Failed to change email address",
+		Data:         nil,
+	})
+}
+
+// sendTemplate renders a mailer template pair and sends it.
+func sendTemplate(ctx context.Context, to, subject, template string, data interface{}) error {
+	text, html, err := mailer.Render(template, data)
+	if err != nil {
+		return err
+	}
+	return mailer.Default.Send(ctx, mailer.Email{
+		To:      to,
+		Subject: subject,
+		Text:    text,
+		HTML:    html,
+	})
+}
+
+func randomToken() (string, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
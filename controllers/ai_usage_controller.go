@@ -0,0 +1,402 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/llm"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// modelPrice is what a model costs in USD per million tokens.
+type modelPrice struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// modelPrices is read from AI_PRICES, a JSON object of model name to price
+var modelPrices = map[string]modelPrice{}
+
+// aiQuotaError says which quota a user has used up and when it resets.
+type aiQuotaError struct {
+	limit   string
+	resetAt time.Time
+}
+
+func (e *aiQuotaError) Error() string {
+	return "AI usage limit reached: " + e.limit
+}
+
+// InitAIAccounting records the usage of every model call, refuses calls
+// over the user's limits (see aiLimits), and answers
+// identical requests from a cache kept for AI_CACHE_TTL (default 24h, 0 to
+// disable).
+func InitAIAccounting() {
+	if prices := os.Getenv("AI_PRICES"); prices != "" {
+		if err := json.Unmarshal([]byte(prices), &modelPrices); err != nil {
+			log.Printf("[ERROR] Invalid AI_PRICES: %v", err)
+		}
+	}
+
+	llm.SetUsageRecorder(recordAIUsage)
+	llm.SetAdmission(reserveAIQuota)
+
+	if ttl := envDuration("AI_CACHE_TTL", 24*time.Hour); ttl > 0 {
+		llm.SetCache(aiCache{ttl: ttl})
+	}
+}
+
+// recordAIUsage adds a call to its user's daily bucket. It has its own
+// timeout because the call's context may already be done.
+func recordAIUsage(_ context.Context, call llm.Call) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now().UTC()
+	inc := bson.M{"cachedRequests": 1}
+	if !call.Cached {
+		price := modelPrices[call.Model]
+		inc = bson.M{
+			"requests":     1,
+			"inputTokens":  call.Usage.InputTokens,
+			"outputTokens": call.Usage.OutputTokens,
+			"costUsd": (float64(call.Usage.InputTokens)*price.Input +
+				float64(call.Usage.OutputTokens)*price.Output) / 1e6,
+		}
+	}
+
+	// Calls for no user in particular, like llm.SystemUser's, are recorded
+	// under that name
+	var user interface{} = call.User
+	userID, idErr := primitive.ObjectIDFromHex(call.User)
+	if idErr == nil {
+		user = userID
+	}
+
+	_, err := utils.DB.Collection("ai_usage").UpdateOne(ctx,
+		bson.M{
+			"userId":  user,
+			"feature": call.Feature,
+			"model":   call.Model,
+			"day":     now.Format("2006-01-02"),
+		},
+		bson.M{
+			"$inc":         inc,
+			"$set":         bson.M{"updatedAt": now},
+			"$setOnInsert": bson.M{"month": now.Format("2006-01")},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to record AI usage for user %s: %v", call.User, err)
+	}
+
+	if tokens := call.Usage.InputTokens + call.Usage.OutputTokens; idErr == nil && !call.Cached && tokens > 0 {
+		if err := addAIQuotaTokens(ctx, userID, call.Feature, tokens); err != nil {
+			log.Printf("[ERROR] Failed to count AI tokens for user %s: %v", call.User, err)
+		}
+	}
+}
+
+// aiWindow is a period that usage is limited over, for every feature (an
+// empty feature) or for one.
+type aiWindow struct {
+	feature string
+	// period is DAILY or MONTHLY
+	period string
+}
+
+// aiLimit is how many tokens and requests a window allows; zero is no
+// limit.
+type aiLimit struct {
+	tokens   int64
+	requests int64
+}
+
+// aiLimits reads the limits that apply to a call for the feature from
+// AI_DAILY_TOKENS, AI_MONTHLY_TOKENS, AI_DAILY_REQUESTS and
+// AI_MONTHLY_REQUESTS across all features, and from
+// AI_<FEATURE>_DAILY_TOKENS and so on for one feature. Unset or invalid
+// limits don't apply.
+func aiLimits(feature string) map[aiWindow]aiLimit {
+	limits := map[aiWindow]aiLimit{}
+	for _, scope := range []string{"", feature} {
+		prefix := "AI_"
+		if scope != "" {
+			prefix += strings.ToUpper(scope) + "_"
+		}
+		for _, period := range []string{"DAILY", "MONTHLY"} {
+			var limit aiLimit
+			for unit, value := range map[string]*int64{"TOKENS": &limit.tokens, "REQUESTS": &limit.requests} {
+				n, err := strconv.ParseInt(os.Getenv(prefix+period+"_"+unit), 10, 64)
+				if err == nil && n > 0 {
+					*value = n
+				}
+			}
+			limits[aiWindow{feature: scope, period: period}] = limit
+		}
+	}
+	return limits
+}
+
+// aiWindowStart returns the key of the window's current period, such as
+// 2006-01-02 for a day, and when it resets.
+func aiWindowStart(period string, now time.Time) (string, time.Time) {
+	if period == "DAILY" {
+		return now.Format("2006-01-02"), time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+	}
+	return now.Format("2006-01"), time.Date(now.Year(), now.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+// reserveAIQuota counts a call that is about to reach a provider against
+// each of the user's windows for the feature, or returns an *aiQuotaError
+// when one of them is used up. Each window's counter is only incremented
+// while it is under its limit, so concurrent calls can't overshoot it.
+// Calls made for no user aren't limited.
+func reserveAIQuota(ctx context.Context, user, feature string) error {
+	userID, err := primitive.ObjectIDFromHex(user)
+	if err != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+
+	var reserved []bson.M
+	release := func() {
+		for _, filter := range reserved {
+			_, err := utils.DB.Collection("ai_quota").UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"requests": -1}})
+			if err != nil {
+				log.Printf("[ERROR] Failed to release AI quota for user %s: %v", user, err)
+			}
+		}
+	}
+
+	limits := aiLimits(feature)
+	for _, scope := range []string{"", feature} {
+		for _, period := range []string{"DAILY", "MONTHLY"} {
+			limit := limits[aiWindow{feature: scope, period: period}]
+			key, resetAt := aiWindowStart(period, now)
+			counter := bson.M{"userId": userID, "feature": scope, "period": key}
+
+			filter := bson.M{}
+			for k, v := range counter {
+				filter[k] = v
+			}
+			if limit.requests > 0 {
+				filter["requests"] = bson.M{"$lt": limit.requests}
+			}
+			if limit.tokens > 0 {
+				filter["tokens"] = bson.M{"$lt": limit.tokens}
+			}
+			update := bson.M{
+				"$inc":         bson.M{"requests": 1},
+				"$setOnInsert": bson.M{"tokens": 0, "expiresAt": resetAt},
+			}
+
+			// A counter that exists but is at its limit doesn't match, so
+			// the upsert collides with it. The first call of a period can
+			// also collide with a concurrent one, so that is tried again
+			upsert := func() error {
+				_, err := utils.DB.Collection("ai_quota").UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+				return err
+			}
+			err := upsert()
+			if mongo.IsDuplicateKeyError(err) {
+				err = upsert()
+			}
+			if mongo.IsDuplicateKeyError(err) {
+				release()
+				return &aiQuotaError{
+					limit:   strings.ToLower(period) + " usage",
+					resetAt: resetAt,
+				}
+			}
+			if err != nil {
+				release()
+				return err
+			}
+			reserved = append(reserved, counter)
+		}
+	}
+	return nil
+}
+
+// addAIQuotaTokens adds the tokens a call used to the user's windows for
+// the feature, which reserveAIQuota has created.
+func addAIQuotaTokens(ctx context.Context, userID primitive.ObjectID, feature string, tokens int) error {
+	now := time.Now().UTC()
+	day, _ := aiWindowStart("DAILY", now)
+	month, _ := aiWindowStart("MONTHLY", now)
+	_, err := utils.DB.Collection("ai_quota").UpdateMany(ctx,
+		bson.M{
+			"userId":  userID,
+			"feature": bson.M{"$in": []string{"", feature}},
+			"period":  bson.M{"$in": []string{day, month}},
+		},
+		bson.M{"$inc": bson.M{"tokens": tokens}},
+	)
+	return err
+}
+
+// aiQuotaExceeded answers a request whose model call was refused by
+// reserveAIQuota, and reports whether it did.
+func aiQuotaExceeded(c *gin.Context, err error) bool {
+	var quotaErr *aiQuotaError
+	if !errors.As(err, &quotaErr) {
+		return false
+	}
+
+	c.Header("Retry-After", strconv.Itoa(int(time.Until(quotaErr.resetAt).Seconds())+1))
+	c.JSON(http.StatusTooManyRequests, models.Response{
+		ResponseCode: http.StatusTooManyRequests,
+		Message:      "You have reached your " + quotaErr.limit + " limit for AI features",
+		Data: gin.H{
+			"resetAt": quotaErr.resetAt,
+		},
+	})
+	return true
+}
+
+// GetAIUsageReport totals AI usage between the from and to days
+// (YYYY-MM-DD, default the current month) grouped by user, feature, model
+// or day.
+func GetAIUsageReport(c *gin.Context) {
+	now := time.Now().UTC()
+	from := c.DefaultQuery("from", now.Format("2006-01")+"-01")
+	to := c.DefaultQuery("to", now.Format("2006-01-02"))
+	groupBy := c.DefaultQuery("groupBy", "user")
+	for _, day := range []string{from, to} {
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			profileBadRequest(c, "from and to must be dates like 2006-01-02")
+			return
+		}
+	}
+	groupFields := map[string]string{
+		"user":    "$userId",
+		"feature": "$feature",
+		"model":   "$model",
+		"day":     "$day",
+	}
+	groupField, ok := groupFields[groupBy]
+	if !ok {
+		profileBadRequest(c, "groupBy must be user, feature, model or day")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"day": bson.M{"$gte": from, "$lte": to}}},
+		{
+			"$group": bson.M{
+				"_id":            groupField,
+				"requests":       bson.M{"$sum": "$requests"},
+				"cachedRequests": bson.M{"$sum": "$cachedRequests"},
+				"inputTokens":    bson.M{"$sum": "$inputTokens"},
+				"outputTokens":   bson.M{"$sum": "$outputTokens"},
+				"costUsd":        bson.M{"$sum": "$costUsd"},
+			},
+		},
+		{"$sort": bson.D{{Key: "costUsd", Value: -1}, {Key: "requests", Value: -1}}},
+	}
+	if groupBy == "user" {
+		pipeline = append(pipeline,
+			bson.M{
+				"$lookup": bson.M{
+					"from":         "users",
+					"localField":   "_id",
+					"foreignField": "_id",
+					"as":           "user",
+				},
+			},
+			bson.M{"$set": bson.M{"username": bson.M{"$arrayElemAt": []interface{}{"$user.username", 0}}}},
+			bson.M{"$unset": "user"},
+		)
+	}
+
+	cursor, err := utils.DB.Collection("ai_usage").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("[ERROR] Failed to build AI usage report: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error building usage report",
+			Data:         nil,
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	rows := []gin.H{}
+	if err := cursor.All(ctx, &rows); err != nil {
+		log.Printf("[ERROR] Failed to decode AI usage report: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error building usage report",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      fmt.Sprintf("AI usage from %s to %s", from, to),
+		Data: gin.H{
+			"groupBy": groupBy,
+			"rows":    rows,
+		},
+	})
+}
+
+// aiCache keeps model responses in MongoDB until they expire.
+type aiCache struct {
+	ttl time.Duration
+}
+
+func (a aiCache) Get(ctx context.Context, key string) (llm.Response, bool) {
+	var entry models.AICacheEntry
+	err := utils.DB.Collection("ai_cache").FindOne(ctx, bson.M{
+		"key":       key,
+		"expiresAt": bson.M{"$gt": time.Now()},
+	}).Decode(&entry)
+	if err != nil {
+		return llm.Response{}, false
+	}
+	return llm.Response{
+		Text:  entry.Text,
+		Model: entry.Model,
+		Usage: llm.Usage{InputTokens: entry.InputTokens, OutputTokens: entry.OutputTokens},
+	}, true
+}
+
+func (a aiCache) Set(ctx context.Context, key string, resp llm.Response) {
+	entry := models.AICacheEntry{
+		Key:          key,
+		Text:         resp.Text,
+		Model:        resp.Model,
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+		ExpiresAt:    time.Now().Add(a.ttl),
+	}
+	_, err := utils.DB.Collection("ai_cache").ReplaceOne(ctx,
+		bson.M{"key": key},
+		entry,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to cache AI response: %v", err)
+	}
+}
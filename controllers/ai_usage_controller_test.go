@@ -0,0 +1,45 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAILimits(t *testing.T) {
+	t.Setenv("AI_DAILY_TOKENS", "1000")
+	t.Setenv("AI_MONTHLY_REQUESTS", "300")
+	t.Setenv("AI_SUMMARIES_DAILY_REQUESTS", "5")
+	t.Setenv("AI_SUMMARIES_MONTHLY_TOKENS", "nonsense")
+	t.Setenv("AI_TRANSLATION_DAILY_REQUESTS", "7")
+	t.Setenv("AI_DAILY_REQUESTS", "-1")
+
+	got := aiLimits("summaries")
+	want := map[aiWindow]aiLimit{
+		{feature: "", period: "DAILY"}:            {tokens: 1000},
+		{feature: "", period: "MONTHLY"}:          {requests: 300},
+		{feature: "summaries", period: "DAILY"}:   {requests: 5},
+		{feature: "summaries", period: "MONTHLY"}: {},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("aiLimits() = %+v, want %+v", got, want)
+	}
+}
+
+func TestAIWindowStart(t *testing.T) {
+	now := time.Date(2024, 12, 31, 18, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period  string
+		key     string
+		resetAt time.Time
+	}{
+		{"DAILY", "2024-12-31", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"MONTHLY", "2024-12", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		key, resetAt := aiWindowStart(tt.period, now)
+		if key != tt.key || !resetAt.Equal(tt.resetAt) {
+			t.Errorf("aiWindowStart(%s) = %s, %s, want %s, %s", tt.period, key, resetAt, tt.key, tt.resetAt)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxAttachmentSize is the largest file that can be uploaded.
+const maxAttachmentSize = 10 << 20
+
+// attachmentTypes are the file types that may be uploaded, as sniffed from
+// their content, with the extension they are stored under. Office documents
+// sniff as zip archives.
+var attachmentTypes = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+	"application/zip": ".zip",
+}
+
+// UploadAttachment stores a file sent as the "file" field of a multipart
+// form and returns its ID and URL.
+func UploadAttachment(c *gin.Context) {
+	ownerID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentSize+1<<20)
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "A file is required",
+			Data:         nil,
+		})
+		return
+	}
+	if fileHeader.Size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, models.Response{
+			ResponseCode: http.StatusRequestEntityTooLarge,
+			Message:      "File is too large",
+			Data:         nil,
+		})
+		return
+	}
+
+	// The client's name and type for the file aren't trusted
+	contentType, err := sniffContentType(fileHeader)
+	if err != nil {
+		log.Printf("[ERROR] Failed to read upload: %v", err)
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Failed to read file",
+			Data:         nil,
+		})
+		return
+	}
+	ext, ok := attachmentTypes[contentType]
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, models.Response{
+			ResponseCode: http.StatusUnsupportedMediaType,
+			Message:      "Only images, PDFs, text files and office documents can be uploaded",
+			Data:         nil,
+		})
+		return
+	}
+
+	attachment := models.Attachment{
+		ID:          primitive.NewObjectID(),
+		OwnerID:     ownerID,
+		Filename:    filepath.Base(fileHeader.Filename),
+		ContentType: contentType,
+		Size:        fileHeader.Size,
+		CreatedAt:   time.Now(),
+	}
+	// Stored under a generated name so uploads can't collide or escape the
+	// upload directory
+	attachment.Path = attachment.ID.Hex() + ext
+	attachment.URL = "/uploads/" + attachment.Path
+
+	if err := os.MkdirAll(utils.UploadDir(), 0o755); err != nil {
+		log.Printf("[ERROR] Failed to create upload directory: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to store file",
+			Data:         nil,
+		})
+		return
+	}
+	if err := c.SaveUploadedFile(fileHeader, filepath.Join(utils.UploadDir(), attachment.Path)); err != nil {
+		log.Printf("[ERROR] Failed to save upload: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to store file",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := utils.DB.Collection("attachments").InsertOne(ctx, attachment); err != nil {
+		log.Printf("[ERROR] Failed to save attachment: %v", err)
+		os.Remove(filepath.Join(utils.UploadDir(), attachment.Path))
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to store file",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "File uploaded successfully",
+		Data:         attachment,
+	})
+}
+
+// sniffContentType detects the type of an uploaded file from its first
+// bytes, without parameters such as the charset.
+func sniffContentType(fileHeader *multipart.FileHeader) (string, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return "", err
+	}
+	contentType, _, _ := strings.Cut(http.DetectContentType(head[:n]), ";")
+	return contentType, nil
+}
+
+// ServeAttachment sends an uploaded file to its owner, to the other member
+// of a conversation it was sent in, or to anyone signed in when it is a
+// user's avatar. Only images are shown inline; everything
+// else is downloaded.
+func ServeAttachment(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var attachment models.Attachment
+	err := utils.DB.Collection("attachments").FindOne(ctx, bson.M{"path": c.Param("name")}).Decode(&attachment)
+	if err == nil && attachment.OwnerID != userID {
+		var allowed bool
+		allowed, err = canViewAttachment(ctx, userID, attachment)
+		if err == nil && !allowed {
+			err = mongo.ErrNoDocuments
+		}
+	}
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "File not found",
+			Data:         nil,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to load attachment: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to load file",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Type", attachment.ContentType)
+	if !strings.HasPrefix(attachment.ContentType, "image/") {
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": attachment.Filename,
+		}))
+	}
+	c.File(filepath.Join(utils.UploadDir(), attachment.Path))
+}
+
+// canViewAttachment reports whether a user other than the owner may see an
+// attachment.
+func canViewAttachment(ctx context.Context, userID primitive.ObjectID, attachment models.Attachment) (bool, error) {
+	sent, err := utils.DB.Collection("messages").CountDocuments(ctx,
+		bson.M{
+			"attachments._id": attachment.ID,
+			"senderID":        attachment.OwnerID,
+			"receiverID":      userID,
+		},
+		options.Count().SetLimit(1),
+	)
+	if err != nil || sent > 0 {
+		return sent > 0, err
+	}
+	avatars, err := utils.DB.Collection("users").CountDocuments(ctx,
+		bson.M{"avatarUrl": attachment.URL},
+		options.Count().SetLimit(1),
+	)
+	return avatars > 0, err
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -11,13 +12,15 @@ import (
 	"github.com/sajanIocod/chat_backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 func Register(c *gin.Context) {
-	var user models.User
-	if err := c.BindJSON(&user); err != nil {
+	var req models.RegisterRequest
+	if err := c.BindJSON(&req); err != nil {
 		c.JSON(400, models.Response{
 			ResponseCode: 400,
 			Message:      "Invalid request data",
@@ -25,6 +28,11 @@ func Register(c *gin.Context) {
 		})
 		return
 	}
+	user := models.User{
+		Username: req.Username,
+		Email:    req.Email,
+		Password: req.Password,
+	}
 
 	collection := utils.DB.Collection("users")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -43,6 +51,27 @@ func Register(c *gin.Context) {
 		return
 	}
 
+	// Usernames are unique regardless of case
+	user.Username = strings.TrimSpace(user.Username)
+	if user.Username == "" {
+		c.JSON(400, models.Response{
+			ResponseCode: 400,
+			Message:      "Username is required",
+			Data:         nil,
+		})
+		return
+	}
+	existingCount, _ = collection.CountDocuments(ctx, bson.M{"username": user.Username},
+		options.Count().SetCollation(utils.UsernameCollation))
+	if existingCount > 0 {
+		c.JSON(400, models.Response{
+			ResponseCode: 400,
+			Message:      "Username already taken",
+			Data:         nil,
+		})
+		return
+	}
+
 	// Hash password
 	hash, err := bcrypt.GenerateFromPassword([]byte(user.Password), bcrypt.DefaultCost)
 	if err != nil {
@@ -56,6 +85,14 @@ func Register(c *gin.Context) {
 	user.Password = string(hash)
 	// Insert user
 	_, err = collection.InsertOne(ctx, user)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(400, models.Response{
+			ResponseCode: 400,
+			Message:      "Username already taken",
+			Data:         nil,
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(500, models.Response{
 			ResponseCode: 500,
@@ -66,8 +103,7 @@ func Register(c *gin.Context) {
 	}
 
 	// Generate JWT token
-	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID.Hex())
+	token, err := utils.NewSessionToken(user.ID)
 	if err != nil {
 		c.JSON(500, models.Response{
 			ResponseCode: 500,
@@ -124,8 +160,13 @@ func Login(c *gin.Context) {
 		})
 		return
 	}
+	// Logging in during the grace period keeps the account
+	message := "Login successful"
+	if cancelAccountDeletion(ctx, user) {
+		message = "Login successful, account deletion cancelled"
+	}
 	// Generate JWT token
-	token, err := utils.GenerateJWT(user.ID.Hex())
+	token, err := utils.NewSessionToken(user.ID)
 	if err != nil {
 		c.JSON(500, models.Response{
 			ResponseCode: 500,
@@ -143,47 +184,24 @@ func Login(c *gin.Context) {
 	}
 	c.JSON(200, models.Response{
 		ResponseCode: 200,
-		Message:      "Login successful",
+		Message:      message,
 		Data:         userResp,
 	})
 
 }
 
-func Profile(c *gin.Context) {
-	userID, exists := c.Get("userID")
-	if !exists {
-		c.JSON(500, gin.H{"error": "User ID not found in context"})
-		return
-	}
-
-	c.JSON(200, gin.H{
-		"message": "Access granted",
-		"userID":  userID,
-	})
-}
-
+// Logout ends the caller's session, so its token stops working.
 func Logout(c *gin.Context) {
-	tokenString := c.GetHeader("Authorization") // Bearer <token>
-	if tokenString == "" {
-		c.JSON(http.StatusBadRequest, models.Response{
-			ResponseCode: http.StatusBadRequest,
-			Message:      "Authorization header is required",
-			Data:         nil,
-		})
-		return
-	}
-	token := strings.TrimPrefix(tokenString, "Bearer ")
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
 
-	// Save to blacklist
-	blacklisted := models.BlacklistedToken{
-		Token:     token,
-		ExpiredAt: time.Now().Add(time.Hour * 24), // depends on token expiry
-	}
-	_, err := utils.DB.Collection("blacklisted_tokens").InsertOne(context.TODO(), blacklisted)
-	if err != nil {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := utils.EndSession(ctx, userID, c.GetString("sessionID")); err != nil {
+		log.Printf("[ERROR] Failed to end session for user %s: %v", userID.Hex(), err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			ResponseCode: http.StatusInternalServerError,
-			Message:      "Failed to blacklist token",
+			Message:      "Failed to log out",
 			Data:         nil,
 		})
 		return
@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sajanIocod/chat_backend/llm"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// defaultBotContextMessages is how many messages a bot sees when its
+	// configuration doesn't say.
+	defaultBotContextMessages = 20
+	// defaultBotRepliesPerMinute caps a bot's replies to one user when its
+	// configuration doesn't say.
+	defaultBotRepliesPerMinute = 6
+	// botReplyTimeout bounds loading context, generating and sending one
+	// reply.
+	botReplyTimeout = time.Minute
+)
+
+// botConversation tracks a bot's replies to one user. Messages that arrive
+// while a reply is being written are answered together by one more reply.
+type botConversation struct {
+	busy    bool
+	pending bool
+	replies []time.Time
+}
+
+var (
+	botMu            sync.Mutex
+	botConversations = map[string]*botConversation{}
+)
+
+// EnsureBots creates or updates the bot users listed in the JSON file named
+// by BOTS_FILE. Bots can't log in; they only answer messages.
+func EnsureBots() {
+	path := os.Getenv("BOTS_FILE")
+	if path == "" {
+		return
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Printf("[ERROR] Failed to read bots file: %v", err)
+		return
+	}
+	var definitions []models.BotDefinition
+	if err := json.Unmarshal(data, &definitions); err != nil {
+		log.Printf("[ERROR] Failed to parse bots file: %v", err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, def := range definitions {
+		username := strings.TrimSpace(def.Username)
+		if username == "" || def.SystemPrompt == "" {
+			log.Printf("[ERROR] Skipping bot without a username or system prompt")
+			continue
+		}
+
+		// Only ever matches a bot created here, so no other user's account
+		// is taken over
+		_, err := utils.DB.Collection("users").UpdateOne(ctx,
+			bson.M{"username": username, "botManaged": true},
+			bson.M{
+				"$set": bson.M{
+					"displayName": def.DisplayName,
+					"bio":         def.Bio,
+					"avatarUrl":   def.AvatarURL,
+					"bot": models.BotConfig{
+						SystemPrompt:     def.SystemPrompt,
+						ContextMessages:  def.ContextMessages,
+						RepliesPerMinute: def.RepliesPerMinute,
+					},
+				},
+				"$setOnInsert": bson.M{"email": "", "password": "", "isBot": true},
+			},
+			options.Update().SetUpsert(true).SetCollation(utils.UsernameCollation),
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			log.Printf("[ERROR] Bot username %s is taken by a user", username)
+			continue
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to save bot %s: %v", username, err)
+			continue
+		}
+		log.Printf("[INFO] Bot %s ready", username)
+	}
+}
+
+// replyAsBot has the bot answer a message it received, unless it is already
+// answering that user, in which case the reply in progress is followed by
+// one more covering the new messages.
+func replyAsBot(bot models.User, message models.Message) {
+	if bot.Bot == nil {
+		return
+	}
+	key := typingKey(bot.ID, message.SenderID)
+
+	botMu.Lock()
+	conversation, ok := botConversations[key]
+	if !ok {
+		conversation = &botConversation{}
+		botConversations[key] = conversation
+	}
+	if conversation.busy {
+		conversation.pending = true
+		botMu.Unlock()
+		return
+	}
+	conversation.busy = true
+	botMu.Unlock()
+
+	go runBotReplies(bot, message.SenderID, key, conversation)
+}
+
+func runBotReplies(bot models.User, userID primitive.ObjectID, key string, conversation *botConversation) {
+	limit := bot.Bot.RepliesPerMinute
+	if limit <= 0 {
+		limit = defaultBotRepliesPerMinute
+	}
+
+	for {
+		botMu.Lock()
+		now := time.Now()
+		recent := conversation.replies[:0]
+		for _, at := range conversation.replies {
+			if now.Sub(at) < time.Minute {
+				recent = append(recent, at)
+			}
+		}
+		conversation.replies = recent
+		if len(recent) >= limit {
+			conversation.busy = false
+			conversation.pending = false
+			botMu.Unlock()
+			log.Printf("[WARN] Bot %s rate limited for user %s", bot.ID.Hex(), userID.Hex())
+			return
+		}
+		conversation.replies = append(conversation.replies, now)
+		conversation.pending = false
+		botMu.Unlock()
+
+		if err := sendBotReply(bot, userID); err != nil {
+			log.Printf("[ERROR] Bot %s failed to reply to user %s: %v", bot.ID.Hex(), userID.Hex(), err)
+		}
+
+		botMu.Lock()
+		if !conversation.pending {
+			conversation.busy = false
+			if len(conversation.replies) == 0 {
+				delete(botConversations, key)
+			}
+			botMu.Unlock()
+			return
+		}
+		botMu.Unlock()
+	}
+}
+
+// sendBotReply generates the bot's next message to the user from their
+// recent conversation and sends it like any other message.
+func sendBotReply(bot models.User, userID primitive.ObjectID) error {
+	ctx, cancel := context.WithTimeout(context.Background(), botReplyTimeout)
+	defer cancel()
+
+	window := int64(bot.Bot.ContextMessages)
+	if window <= 0 {
+		window = defaultBotContextMessages
+	}
+	history, err := loadConversationContext(ctx, bot.ID, userID, window)
+	if err != nil || history == nil {
+		return err
+	}
+
+	// Show the bot typing while the reply is written
+	startTyping(bot.ID, userID)
+
+	system := bot.Bot.SystemPrompt
+	messages := make([]llm.Message, 0, len(history))
+	for _, msg := range history {
+		role := llm.RoleUser
+		if msg.Role == "me" {
+			role = llm.RoleAssistant
+		} else {
+			system = bot.Bot.SystemPrompt + "\n\nYou are chatting with " + msg.Name + "."
+		}
+		messages = append(messages, llm.Message{Role: role, Content: msg.Content})
+	}
+
+	// Bot replies count against the human's AI usage
+	result, err := llm.Generate(llm.WithUser(ctx, userID.Hex()), llm.FeatureBots, llm.Request{
+		System:   system,
+		Messages: messages,
+	})
+	content := strings.TrimSpace(result.Text)
+	if err != nil || content == "" {
+		stopTyping(bot.ID, userID)
+		return err
+	}
+
+	message := models.Message{
+		ID:         primitive.NewObjectID(),
+		SenderID:   bot.ID,
+		ReceiverID: userID,
+		Content:    content,
+		Seen:       false,
+		Status:     models.MessageStatusSent,
+		CreatedAt:  time.Now(),
+	}
+	if _, err := utils.DB.Collection("messages").InsertOne(ctx, message); err != nil {
+		stopTyping(bot.ID, userID)
+		return err
+	}
+	deliverMessage(ctx, message, "")
+	return nil
+}
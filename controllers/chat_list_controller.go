@@ -11,9 +11,20 @@ import (
 	"github.com/sajanIocod/chat_backend/models"
 	"github.com/sajanIocod/chat_backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func GetChatList(c *gin.Context) {
+	listChats(c, false)
+}
+
+// GetMessageRequests lists conversations started by users who aren't
+// contacts and that the caller hasn't answered or accepted yet.
+func GetMessageRequests(c *gin.Context) {
+	listChats(c, true)
+}
+
+func listChats(c *gin.Context, requests bool) {
 	userID := c.GetString("userID")
 	currentUserID := utils.ObjectIDFromHex(userID)
 
@@ -21,6 +32,7 @@ func GetChatList(c *gin.Context) {
 	pageStr := c.DefaultQuery("page", "1")
 	limitStr := c.DefaultQuery("limit", "20")
 	search := c.Query("search")
+	archived := c.Query("archived") == "true"
 
 	page, _ := strconv.Atoi(pageStr)
 	limit, _ := strconv.Atoi(limitStr)
@@ -29,8 +41,8 @@ func GetChatList(c *gin.Context) {
 	}
 	skip := (page - 1) * limit
 
-	log.Printf("[INFO] Fetching chat list for user: %s (page: %d, limit: %d, search: %s)",
-		userID, page, limit, search)
+	log.Printf("[INFO] Fetching chat list for user: %s (page: %d, limit: %d, search: %s, archived: %t)",
+		userID, page, limit, search, archived)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -69,6 +81,7 @@ func GetChatList(c *gin.Context) {
 					},
 				},
 				"lastMessage": bson.M{"$first": "$$ROOT"},
+				"sentByMe":    sentByMe(currentUserID),
 			},
 		},
 		{
@@ -79,11 +92,17 @@ func GetChatList(c *gin.Context) {
 				"as":           "user",
 			},
 		},
+		// Conversations with deleted accounts stay listed, without a user
 		{
-			"$unwind": "$user",
+			"$unwind": bson.M{"path": "$user", "preserveNullAndEmptyArrays": true},
 		},
 	}
 
+	// Only archived or only unarchived conversations, and message requests
+	// kept apart from accepted conversations
+	countPipeline = append(countPipeline, conversationSettingsStages(currentUserID, archived)...)
+	countPipeline = append(countPipeline, messageRequestStages(currentUserID, requests)...)
+
 	// Add search match if search parameter is provided
 	if search != "" {
 		countPipeline = append(countPipeline, bson.M{"$match": searchMatch})
@@ -141,6 +160,7 @@ func GetChatList(c *gin.Context) {
 					},
 				},
 				"lastMessage": bson.M{"$first": "$$ROOT"},
+				"sentByMe":    sentByMe(currentUserID),
 				"unreadCount": bson.M{
 					"$sum": bson.M{
 						"$cond": []interface{}{
@@ -165,28 +185,64 @@ func GetChatList(c *gin.Context) {
 				"as":           "user",
 			},
 		},
+		// Conversations with deleted accounts stay listed, without a user
 		{
-			"$unwind": "$user",
+			"$unwind": bson.M{"path": "$user", "preserveNullAndEmptyArrays": true},
 		},
 	}
 
+	pipeline = append(pipeline, conversationSettingsStages(currentUserID, archived)...)
+	pipeline = append(pipeline, messageRequestStages(currentUserID, requests)...)
+
 	// Add search match if search parameter is provided
 	if search != "" {
 		pipeline = append(pipeline, bson.M{"$match": searchMatch})
 	}
 
-	// Add projection, skip and limit stages
+	// Add presence, projection, skip and limit stages
 	pipeline = append(pipeline,
+		bson.M{
+			"$lookup": bson.M{
+				"from":         "presence",
+				"localField":   "_id",
+				"foreignField": "_id",
+				"as":           "presence",
+			},
+		},
 		bson.M{
 			"$project": bson.M{
 				"userId":          "$_id",
 				"username":        "$user.username",
 				"email":           "$user.email",
+				"isBot":           bson.M{"$ifNull": []interface{}{"$user.isBot", false}},
 				"lastMessage":     "$lastMessage.content",
 				"lastMessageTime": "$lastMessage.createdAt",
 				"unreadCount":     1,
+				"online": bson.M{
+					"$ifNull": []interface{}{bson.M{"$arrayElemAt": []interface{}{"$presence.online", 0}}, false},
+				},
+				"lastSeenAt": bson.M{
+					"$cond": []interface{}{
+						bson.M{"$eq": []interface{}{"$user.hideLastSeen", true}},
+						nil,
+						bson.M{"$arrayElemAt": []interface{}{"$presence.lastSeenAt", 0}},
+					},
+				},
+				"mutedUntil": "$settings.mutedUntil",
+				"archived":   "$settings.archived",
+				"pinned":     "$settings.pinned",
+				// Only pinned conversations are ordered by pinOrder
+				"pinOrder": bson.M{
+					"$cond": []interface{}{bson.M{"$eq": []interface{}{"$settings.pinned", true}}, "$settings.pinOrder", 0},
+				},
 			},
 		},
+		// Pinned conversations first in their chosen order, then by activity
+		bson.M{"$sort": bson.D{
+			{Key: "pinned", Value: -1},
+			{Key: "pinOrder", Value: 1},
+			{Key: "lastMessageTime", Value: -1},
+		}},
 		bson.M{"$skip": skip},
 		bson.M{"$limit": limit},
 	)
@@ -230,3 +286,113 @@ func GetChatList(c *gin.Context) {
 		},
 	})
 }
+
+// conversationSettingsStages joins the user's own settings for each
+// conversation and keeps either the archived or the unarchived ones.
+func conversationSettingsStages(currentUserID primitive.ObjectID, archived bool) []bson.M {
+	return []bson.M{
+		{
+			"$lookup": bson.M{
+				"from": "conversation_settings",
+				"let":  bson.M{"peerId": "$_id"},
+				"pipeline": []bson.M{
+					{"$match": bson.M{
+						"userId": currentUserID,
+						"$expr":  bson.M{"$eq": []interface{}{"$peerId", "$$peerId"}},
+					}},
+				},
+				"as": "settings",
+			},
+		},
+		{
+			"$addFields": bson.M{
+				"settings": bson.M{
+					"$ifNull": []interface{}{
+						bson.M{"$arrayElemAt": []interface{}{"$settings", 0}},
+						bson.M{},
+					},
+				},
+			},
+		},
+		{
+			"$addFields": bson.M{
+				"settings.archived": bson.M{"$ifNull": []interface{}{"$settings.archived", false}},
+				"settings.pinned":   bson.M{"$ifNull": []interface{}{"$settings.pinned", false}},
+				"settings.pinOrder": bson.M{"$ifNull": []interface{}{"$settings.pinOrder", 0}},
+			},
+		},
+		{
+			"$match": bson.M{"settings.archived": archived},
+		},
+	}
+}
+
+// sentByMe is a $group accumulator that is 1 once the user has sent any
+// message in the conversation.
+func sentByMe(currentUserID primitive.ObjectID) bson.M {
+	return bson.M{
+		"$max": bson.M{
+			"$cond": []interface{}{
+				bson.M{"$eq": []interface{}{"$senderID", currentUserID}},
+				1,
+				0,
+			},
+		},
+	}
+}
+
+// messageRequestStages keeps either the accepted conversations or the
+// message requests. A conversation is accepted once the user has written in
+// it or the peer is a contact; declined requests appear in neither list.
+func messageRequestStages(currentUserID primitive.ObjectID, requests bool) []bson.M {
+	stages := []bson.M{
+		{
+			"$lookup": bson.M{
+				"from": "contacts",
+				"let":  bson.M{"peerId": "$_id"},
+				"pipeline": []bson.M{
+					{"$match": bson.M{
+						"$expr": bson.M{
+							"$or": []bson.M{
+								{"$and": []bson.M{
+									{"$eq": []interface{}{"$requesterId", currentUserID}},
+									{"$eq": []interface{}{"$addresseeId", "$$peerId"}},
+								}},
+								{"$and": []bson.M{
+									{"$eq": []interface{}{"$requesterId", "$$peerId"}},
+									{"$eq": []interface{}{"$addresseeId", currentUserID}},
+								}},
+							},
+						},
+					}},
+				},
+				"as": "contact",
+			},
+		},
+		{
+			"$addFields": bson.M{
+				"contactStatus": bson.M{"$arrayElemAt": []interface{}{"$contact.status", 0}},
+			},
+		},
+	}
+
+	if requests {
+		return append(stages, bson.M{
+			"$match": bson.M{
+				"sentByMe": 0,
+				"contactStatus": bson.M{"$nin": []string{
+					models.ContactStatusAccepted,
+					models.ContactStatusDeclined,
+				}},
+			},
+		})
+	}
+	return append(stages, bson.M{
+		"$match": bson.M{
+			"$or": []bson.M{
+				{"sentByMe": 1},
+				{"contactStatus": models.ContactStatusAccepted},
+			},
+		},
+	})
+}
@@ -0,0 +1,494 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SendContactRequest asks another user to become a contact. If they already
+// asked the caller, the request is accepted instead. Users who are blocked
+// either way, or whose request was declined, can't ask.
+func SendContactRequest(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	otherID, ok := contactParam(c, currentUser)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	exists, err := utils.DB.Collection("users").CountDocuments(ctx, bson.M{"_id": otherID})
+	if err != nil || exists == 0 {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "User not found",
+			Data:         nil,
+		})
+		return
+	}
+
+	// A block either way looks like the user doesn't exist
+	blockedByMe, blockedMe, err := blockBetween(ctx, currentUser, otherID)
+	if err != nil {
+		contactError(c, "Failed to send contact request", err)
+		return
+	}
+	if blockedByMe || blockedMe {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "User not found",
+			Data:         nil,
+		})
+		return
+	}
+
+	contact, err := findContact(ctx, currentUser, otherID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		contactError(c, "Failed to send contact request", err)
+		return
+	}
+
+	if err == nil && contact.Status == models.ContactStatusAccepted {
+		c.JSON(http.StatusOK, models.Response{
+			ResponseCode: http.StatusOK,
+			Message:      "Already a contact",
+			Data:         contact,
+		})
+		return
+	}
+	if err == nil && contact.Status == models.ContactStatusPending && contact.AddresseeID == currentUser {
+		respondToContact(c, currentUser, otherID, models.ContactStatusAccepted)
+		return
+	}
+
+	if err == nil && contact.Status == models.ContactStatusPending {
+		c.JSON(http.StatusOK, models.Response{
+			ResponseCode: http.StatusOK,
+			Message:      "Contact request already sent",
+			Data:         contact,
+		})
+		return
+	}
+	// A declined request stays declined; only the user who declined it
+	// can start over by asking themselves
+	if err == nil && contact.Status == models.ContactStatusDeclined && contact.RequesterID == currentUser {
+		c.JSON(http.StatusForbidden, models.Response{
+			ResponseCode: http.StatusForbidden,
+			Message:      "This user declined your contact request",
+			Data:         nil,
+		})
+		return
+	}
+
+	// A new request, or one from the user who declined the last
+	now := time.Now()
+	contact = models.Contact{
+		Key:         utils.ConversationKey(currentUser, otherID),
+		RequesterID: currentUser,
+		AddresseeID: otherID,
+		Status:      models.ContactStatusPending,
+		CreatedAt:   now,
+	}
+	_, err = utils.DB.Collection("contacts").ReplaceOne(ctx,
+		bson.M{"key": contact.Key},
+		contact,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		contactError(c, "Failed to send contact request", err)
+		return
+	}
+
+	notifyContactEvent(otherID, "contact-request", currentUser)
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Contact request sent",
+		Data:         contact,
+	})
+}
+
+// AcceptContact accepts a contact request or a message request from another
+// user.
+func AcceptContact(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	if otherID, ok := contactParam(c, currentUser); ok {
+		respondToContact(c, currentUser, otherID, models.ContactStatusAccepted)
+	}
+}
+
+// DeclineContact declines a contact request or a message request from
+// another user.
+func DeclineContact(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	if otherID, ok := contactParam(c, currentUser); ok {
+		respondToContact(c, currentUser, otherID, models.ContactStatusDeclined)
+	}
+}
+
+// RemoveContact deletes the contact between the caller and another user.
+func RemoveContact(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	otherID, ok := contactParam(c, currentUser)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := utils.DB.Collection("contacts").DeleteOne(ctx, bson.M{
+		"key": utils.ConversationKey(currentUser, otherID),
+	})
+	if err != nil {
+		contactError(c, "Failed to remove contact", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Contact removed",
+		Data:         nil,
+	})
+}
+
+// GetContacts lists the caller's accepted contacts.
+func GetContacts(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	listContacts(c, bson.M{
+		"status": models.ContactStatusAccepted,
+		"$or": []bson.M{
+			{"requesterId": currentUser},
+			{"addresseeId": currentUser},
+		},
+	}, currentUser, "Contacts fetched successfully")
+}
+
+// GetContactRequests lists pending contact requests sent to the caller.
+func GetContactRequests(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	listContacts(c, bson.M{
+		"status":      models.ContactStatusPending,
+		"addresseeId": currentUser,
+	}, currentUser, "Contact requests fetched successfully")
+}
+
+// UpdateContactSettings sets who may start a conversation with the caller.
+func UpdateContactSettings(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.ContactSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request: messagePermission must be everyone or contacts",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := utils.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": currentUser},
+		bson.M{"$set": bson.M{"messagePermission": req.MessagePermission}},
+	)
+	if err != nil {
+		contactError(c, "Failed to update contact settings", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Contact settings updated",
+		Data:         req,
+	})
+}
+
+func contactParam(c *gin.Context, currentUser primitive.ObjectID) (primitive.ObjectID, bool) {
+	otherID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil || otherID == currentUser {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid user ID",
+			Data:         nil,
+		})
+		return primitive.NilObjectID, false
+	}
+	return otherID, true
+}
+
+func contactError(c *gin.Context, message string, err error) {
+	log.Printf("[ERROR] %s: %v", message, err)
+	c.JSON(http.StatusInternalServerError, models.Response{
+		ResponseCode: http.StatusInternalServerError,
+		Message:      message,
+		Data:         nil,
+	})
+}
+
+// respondToContact records the caller's answer to a request from otherID:
+// either a pending contact request addressed to the caller or, with no
+// contact on record, a message request. Anything else is not found.
+func respondToContact(c *gin.Context, currentUser, otherID primitive.ObjectID, status string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	blockedByMe, blockedMe, err := blockBetween(ctx, currentUser, otherID)
+	if err != nil {
+		contactError(c, "Failed to respond to request", err)
+		return
+	}
+	if blockedByMe || blockedMe {
+		requestNotFound(c)
+		return
+	}
+
+	contact, err := findContact(ctx, currentUser, otherID)
+	if err != nil && err != mongo.ErrNoDocuments {
+		contactError(c, "Failed to respond to request", err)
+		return
+	}
+	found := err == nil
+	if found && contact.Status == models.ContactStatusPending && contact.RequesterID == currentUser {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "You can't respond to your own request",
+			Data:         nil,
+		})
+		return
+	}
+
+	now := time.Now()
+	switch {
+	case found && contact.Status == models.ContactStatusPending && contact.AddresseeID == currentUser:
+		// Only answer the request if it is still pending
+		result, err := utils.DB.Collection("contacts").UpdateOne(ctx,
+			bson.M{
+				"key":         contact.Key,
+				"status":      models.ContactStatusPending,
+				"addresseeId": currentUser,
+			},
+			bson.M{"$set": bson.M{"status": status, "respondedAt": now}},
+		)
+		if err != nil {
+			contactError(c, "Failed to respond to request", err)
+			return
+		}
+		if result.MatchedCount == 0 {
+			requestNotFound(c)
+			return
+		}
+
+	case !found:
+		// A message request: otherID must have written to the caller
+		wrote, err := utils.DB.Collection("messages").CountDocuments(ctx, bson.M{
+			"senderID":   otherID,
+			"receiverID": currentUser,
+		}, options.Count().SetLimit(1))
+		if err != nil {
+			contactError(c, "Failed to respond to request", err)
+			return
+		}
+		if wrote == 0 {
+			requestNotFound(c)
+			return
+		}
+
+		contact = models.Contact{
+			Key:         utils.ConversationKey(currentUser, otherID),
+			RequesterID: otherID,
+			AddresseeID: currentUser,
+			Status:      status,
+			CreatedAt:   now,
+			RespondedAt: &now,
+		}
+		_, err = utils.DB.Collection("contacts").InsertOne(ctx, contact)
+		if mongo.IsDuplicateKeyError(err) {
+			// A contact request was made meanwhile; answer that instead
+			c.JSON(http.StatusConflict, models.Response{
+				ResponseCode: http.StatusConflict,
+				Message:      "The request changed, please try again",
+				Data:         nil,
+			})
+			return
+		}
+		if err != nil {
+			contactError(c, "Failed to respond to request", err)
+			return
+		}
+
+	default:
+		requestNotFound(c)
+		return
+	}
+	contact.Status = status
+	contact.RespondedAt = &now
+
+	if status == models.ContactStatusAccepted {
+		notifyContactEvent(otherID, "contact-accepted", currentUser)
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Request " + status,
+		Data:         contact,
+	})
+}
+
+func requestNotFound(c *gin.Context) {
+	c.JSON(http.StatusNotFound, models.Response{
+		ResponseCode: http.StatusNotFound,
+		Message:      "Request not found",
+		Data:         nil,
+	})
+}
+
+func listContacts(c *gin.Context, filter bson.M, currentUser primitive.ObjectID, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": filter},
+		{"$sort": bson.M{"createdAt": -1}},
+		{
+			"$addFields": bson.M{
+				"otherId": bson.M{
+					"$cond": []interface{}{
+						bson.M{"$eq": []interface{}{"$requesterId", currentUser}},
+						"$addresseeId",
+						"$requesterId",
+					},
+				},
+			},
+		},
+		{
+			"$lookup": bson.M{
+				"from":         "users",
+				"localField":   "otherId",
+				"foreignField": "_id",
+				"as":           "user",
+			},
+		},
+		{"$unwind": "$user"},
+		{
+			"$project": bson.M{
+				"_id":       0,
+				"userId":    "$otherId",
+				"username":  "$user.username",
+				"status":    1,
+				"createdAt": 1,
+			},
+		},
+	}
+
+	cursor, err := utils.DB.Collection("contacts").Aggregate(ctx, pipeline)
+	if err != nil {
+		contactError(c, "Error fetching contacts", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	contacts := []gin.H{}
+	if err := cursor.All(ctx, &contacts); err != nil {
+		contactError(c, "Error processing contacts", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      message,
+		Data:         contacts,
+	})
+}
+
+func findContact(ctx context.Context, a, b primitive.ObjectID) (models.Contact, error) {
+	var contact models.Contact
+	err := utils.DB.Collection("contacts").FindOne(ctx, bson.M{
+		"key": utils.ConversationKey(a, b),
+	}).Decode(&contact)
+	return contact, err
+}
+
+// areContacts reports whether the two users have an accepted contact.
+func areContacts(ctx context.Context, a, b primitive.ObjectID) (bool, error) {
+	contact, err := findContact(ctx, a, b)
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return contact.Status == models.ContactStatusAccepted, nil
+}
+
+// contactIDs returns the users the given user has an accepted contact with.
+func contactIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := utils.DB.Collection("contacts").Find(ctx, bson.M{
+		"status": models.ContactStatusAccepted,
+		"$or": []bson.M{
+			{"requesterId": userID},
+			{"addresseeId": userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var contacts []models.Contact
+	if err := cursor.All(ctx, &contacts); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(contacts))
+	for _, contact := range contacts {
+		if contact.RequesterID == userID {
+			ids = append(ids, contact.AddresseeID)
+		} else {
+			ids = append(ids, contact.RequesterID)
+		}
+	}
+	return ids, nil
+}
+
+// canMessage reports whether the receiver's message permission lets the
+// sender start or continue a conversation with them. Replying to someone
+// who wrote first is always allowed.
+func canMessage(ctx context.Context, sender primitive.ObjectID, receiver models.User) (bool, error) {
+	if receiver.MessagePermission != models.MessagePermissionContacts {
+		return true, nil
+	}
+
+	contacts, err := areContacts(ctx, sender, receiver.ID)
+	if err != nil || contacts {
+		return contacts, err
+	}
+
+	wroteFirst, err := utils.DB.Collection("messages").CountDocuments(ctx, bson.M{
+		"senderID":   receiver.ID,
+		"receiverID": sender,
+	}, options.Count().SetLimit(1))
+	return wroteFirst > 0, err
+}
+
+func notifyContactEvent(userID primitive.ObjectID, event string, fromID primitive.ObjectID) {
+	err := utils.PusherClient.Trigger(utils.UserChannel(userID.Hex()), event, gin.H{
+		"userId": fromID.Hex(),
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to trigger %s event: %v", event, err)
+	}
+}
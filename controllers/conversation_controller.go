@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UpdateConversationSettings mutes, archives or pins a conversation for the
+// caller only. The conversation is identified by the peer's user ID.
+func UpdateConversationSettings(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	peerID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid conversation ID",
+			Data:         nil,
+		})
+		return
+	}
+
+	var req models.ConversationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{"updatedAt": time.Now()}
+	unset := bson.M{}
+	if req.AutoTranslate != nil {
+		if *req.AutoTranslate == "" {
+			unset["autoTranslate"] = ""
+		} else if language, ok := normaliseLanguage(*req.AutoTranslate); ok {
+			set["autoTranslate"] = language
+		} else {
+			c.JSON(http.StatusBadRequest, models.Response{
+				ResponseCode: http.StatusBadRequest,
+				Message:      "autoTranslate must be a language code such as en or pt-BR",
+				Data:         nil,
+			})
+			return
+		}
+	}
+	if req.MutedUntil != nil {
+		set["mutedUntil"] = req.MutedUntil
+	}
+	if req.Archived != nil {
+		set["archived"] = *req.Archived
+	}
+	if req.Pinned != nil {
+		set["pinned"] = *req.Pinned
+	}
+	if req.Pinned != nil && !*req.Pinned {
+		// An unpinned conversation has no place among the pinned ones
+		unset["pinOrder"] = ""
+	} else if req.PinOrder != nil {
+		set["pinOrder"] = *req.PinOrder
+	} else if req.Pinned != nil && *req.Pinned {
+		// Newly pinned conversations go after the existing ones
+		order, err := nextPinOrder(ctx, currentUser)
+		if err != nil {
+			log.Printf("[ERROR] Failed to compute pin order: %v", err)
+			c.JSON(http.StatusInternalServerError, models.Response{
+				ResponseCode: http.StatusInternalServerError,
+				Message:      "Failed to update conversation settings",
+				Data:         nil,
+			})
+			return
+		}
+		set["pinOrder"] = order
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	var settings models.ConversationSettings
+	err = utils.DB.Collection("conversation_settings").FindOneAndUpdate(ctx,
+		bson.M{"userId": currentUser, "peerId": peerID},
+		update,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&settings)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update conversation settings: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to update conversation settings",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Conversation settings updated",
+		Data:         settings,
+	})
+}
+
+func nextPinOrder(ctx context.Context, userID primitive.ObjectID) (int, error) {
+	var last models.ConversationSettings
+	err := utils.DB.Collection("conversation_settings").FindOne(ctx,
+		bson.M{"userId": userID, "pinned": true},
+		options.FindOne().SetSort(bson.M{"pinOrder": -1}),
+	).Decode(&last)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return last.PinOrder + 1, nil
+}
+
+// loadConversationSettings returns the user's settings for a conversation,
+// or the defaults if they never changed any.
+func loadConversationSettings(ctx context.Context, userID, peerID primitive.ObjectID) (models.ConversationSettings, error) {
+	settings := models.ConversationSettings{UserID: userID, PeerID: peerID}
+	err := utils.DB.Collection("conversation_settings").FindOne(ctx,
+		bson.M{"userId": userID, "peerId": peerID},
+	).Decode(&settings)
+	if err == mongo.ErrNoDocuments {
+		return settings, nil
+	}
+	return settings, err
+}
+
+// unarchiveConversation brings an archived conversation back into both
+// participants' chat lists when a new message arrives.
+func unarchiveConversation(ctx context.Context, a, b primitive.ObjectID) error {
+	_, err := utils.DB.Collection("conversation_settings").UpdateMany(ctx,
+		bson.M{
+			"archived": true,
+			"$or": []bson.M{
+				{"userId": a, "peerId": b},
+				{"userId": b, "peerId": a},
+			},
+		},
+		bson.M{"$set": bson.M{"archived": false, "updatedAt": time.Now()}},
+	)
+	return err
+}
@@ -0,0 +1,242 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"github.com/sajanIocod/chat_backend/vectors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DeleteAccount schedules the caller's account for deletion after the grace
+// period set by ACCOUNT_DELETION_GRACE (default 30 days). Every session and
+// device is signed out straight away; logging in again cancels the deletion.
+func DeleteAccount(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request: current password is required",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	user, ok := checkCurrentPassword(ctx, c, userID, req.CurrentPassword)
+	if !ok {
+		return
+	}
+
+	deleteAt := time.Now().Add(envDuration("ACCOUNT_DELETION_GRACE", 30*24*time.Hour))
+	_, err := utils.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$set": bson.M{"deletionScheduledAt": deleteAt}},
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to schedule deletion of user %s: %v", user.ID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to delete account",
+			Data:         nil,
+		})
+		return
+	}
+
+	if err := utils.RevokeSessions(ctx, user.ID, ""); err != nil {
+		log.Printf("[ERROR] Failed to revoke sessions of user %s: %v", user.ID.Hex(), err)
+	}
+	if _, err := utils.DB.Collection("device_tokens").DeleteMany(ctx, bson.M{"userId": user.ID}); err != nil {
+		log.Printf("[ERROR] Failed to remove devices of user %s: %v", user.ID.Hex(), err)
+	}
+
+	if user.Email != "" {
+		err := sendTemplate(ctx, user.Email, "Your account will be deleted", "account_deletion", gin.H{
+			"Username": user.Username,
+			"DeleteAt": deleteAt.UTC().Format("2 January 2006 15:04 MST"),
+		})
+		if err != nil {
+			log.Printf("[ERROR] Failed to send deletion notice to user %s: %v", user.ID.Hex(), err)
+		}
+	}
+
+	log.Printf("[INFO] User %s scheduled for deletion at %s", user.ID.Hex(), deleteAt.Format(time.RFC3339))
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Account scheduled for deletion. Log in again before then to cancel",
+		Data: gin.H{
+			"deletionScheduledAt": deleteAt,
+		},
+	})
+}
+
+// cancelAccountDeletion clears a pending deletion, reporting whether there
+// was one.
+func cancelAccountDeletion(ctx context.Context, user models.User) bool {
+	if user.DeletionScheduledAt == nil {
+		return false
+	}
+	_, err := utils.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": user.ID},
+		bson.M{"$unset": bson.M{"deletionScheduledAt": ""}},
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to cancel deletion of user %s: %v", user.ID.Hex(), err)
+		return false
+	}
+	log.Printf("[INFO] Deletion of user %s cancelled by login", user.ID.Hex())
+	return true
+}
+
+// StartAccountDeletionJob purges accounts whose grace period has ended,
+// checking every ACCOUNT_DELETION_INTERVAL (default 1h).
+func StartAccountDeletionJob() {
+	interval := envDuration("ACCOUNT_DELETION_INTERVAL", time.Hour)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			purgeDueAccounts()
+		}
+	}()
+}
+
+func purgeDueAccounts() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	cursor, err := utils.DB.Collection("users").Find(ctx, bson.M{
+		"deletionScheduledAt": bson.M{"$lte": time.Now()},
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to find accounts due for deletion: %v", err)
+		return
+	}
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		log.Printf("[ERROR] Failed to decode accounts due for deletion: %v", err)
+		return
+	}
+
+	policy := os.Getenv("ACCOUNT_MESSAGE_POLICY")
+	if policy != models.MessagePolicyDelete {
+		policy = models.MessagePolicyAnonymise
+	}
+
+	for _, user := range users {
+		if err := purgeAccount(ctx, user.ID, policy); err != nil {
+			// Left scheduled, so the next run tries again
+			log.Printf("[ERROR] Failed to delete user %s: %v", user.ID.Hex(), err)
+			continue
+		}
+		log.Printf("[INFO] Deleted user %s (messages: %s)", user.ID.Hex(), policy)
+	}
+}
+
+// purgeAccount removes the user and everything tied to them. The user
+// record goes last so a failed run is retried from the start. Reports filed
+// about the user are kept for moderation.
+func purgeAccount(ctx context.Context, userID primitive.ObjectID, policy string) error {
+	if err := purgeMessages(ctx, userID, policy); err != nil {
+		return err
+	}
+
+	var attachments []models.Attachment
+	cursor, err := utils.DB.Collection("attachments").Find(ctx, bson.M{"ownerId": userID})
+	if err != nil {
+		return err
+	}
+	if err := cursor.All(ctx, &attachments); err != nil {
+		return err
+	}
+	for _, attachment := range attachments {
+		err := os.Remove(filepath.Join(utils.UploadDir(), attachment.Path))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	deletes := []struct {
+		collection string
+		filter     bson.M
+	}{
+		{"attachments", bson.M{"ownerId": userID}},
+		{"sessions", bson.M{"userId": userID}},
+		{"device_tokens", bson.M{"userId": userID}},
+		{"email_changes", bson.M{"userId": userID}},
+		{"presence", bson.M{"_id": userID}},
+		{"blocks", bson.M{"$or": []bson.M{{"blockerId": userID}, {"blockedId": userID}}}},
+		{"contacts", bson.M{"$or": []bson.M{{"requesterId": userID}, {"addresseeId": userID}}}},
+		{"conversation_settings", bson.M{"$or": []bson.M{{"userId": userID}, {"peerId": userID}}}},
+		{"read_pointers", bson.M{"conversationKey": bson.M{"$regex": userID.Hex()}}},
+		{"conversation_summaries", bson.M{"userId": userID}},
+		{"ai_usage", bson.M{"userId": userID}},
+		{"ai_quota", bson.M{"userId": userID}},
+		{"reports", bson.M{"reporterId": userID}},
+		{"users", bson.M{"_id": userID}},
+	}
+	for _, d := range deletes {
+		if _, err := utils.DB.Collection(d.collection).DeleteMany(ctx, d.filter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeMessages deletes the user's messages, or under the anonymise policy
+// moves them to a fresh ID that leads back to nobody.
+func purgeMessages(ctx context.Context, userID primitive.ObjectID, policy string) error {
+	messages := utils.DB.Collection("messages")
+	if policy == models.MessagePolicyDelete {
+		filter := bson.M{"$or": []bson.M{{"senderID": userID}, {"receiverID": userID}}}
+		ids, err := messages.Distinct(ctx, "_id", filter)
+		if err != nil {
+			return err
+		}
+		if len(ids) > 0 {
+			_, err = utils.DB.Collection("message_translations").DeleteMany(ctx, bson.M{"messageId": bson.M{"$in": ids}})
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := messages.DeleteMany(ctx, filter); err != nil {
+			return err
+		}
+		return vectors.DeleteUser(ctx, userID)
+	}
+
+	anonymousID := primitive.NewObjectID()
+	if err := vectors.ReplaceUser(ctx, userID, anonymousID); err != nil {
+		return err
+	}
+	_, err := messages.UpdateMany(ctx,
+		bson.M{"senderID": userID},
+		bson.M{
+			"$set":   bson.M{"senderID": anonymousID},
+			"$unset": bson.M{"clientMessageId": ""},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	_, err = messages.UpdateMany(ctx,
+		bson.M{"receiverID": userID},
+		bson.M{"$set": bson.M{"receiverID": anonymousID}},
+	)
+	return err
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/mailer"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// digestSnippetsPerSender is how many recent messages are quoted for
+	// each sender in a digest.
+	digestSnippetsPerSender = 3
+	// digestSnippetLength is the longest quoted message in a digest.
+	digestSnippetLength = 140
+)
+
+// digestGroup is the unread, undigested mail of one receiver.
+type digestGroup struct {
+	ReceiverID primitive.ObjectID  `bson:"_id"`
+	Total      int                 `bson:"total"`
+	Senders    []digestGroupSender `bson:"senders"`
+}
+
+// digestGroupSender is one sender's share of a digestGroup.
+type digestGroupSender struct {
+	SenderID   primitive.ObjectID   `bson:"senderId"`
+	Count      int                  `bson:"count"`
+	Snippets   []string             `bson:"snippets"`
+	MessageIDs []primitive.ObjectID `bson:"messageIds"`
+}
+
+// digestData is what the digest email templates render.
+type digestData struct {
+	Username       string
+	Total          int
+	Senders        []digestSender
+	UnsubscribeURL string
+}
+
+type digestSender struct {
+	Username string
+	Count    int
+	Snippets []string
+}
+
+// UnsubscribeDigest turns off digest emails for the user the link was
+// generated for. It is reached from the email, so it takes a signed token
+// rather than a login.
+func UnsubscribeDigest(c *gin.Context) {
+	userID, err := utils.ParseUnsubscribeToken(c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid unsubscribe link",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = utils.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": utils.ObjectIDFromHex(userID)},
+		bson.M{"$set": bson.M{"emailDigestsDisabled": true}},
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to unsubscribe user %s from digests: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to unsubscribe",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "You have been unsubscribed from digest emails",
+		Data:         nil,
+	})
+}
+
+// StartDigestJob periodically emails users about messages that have been
+// unread for longer than DIGEST_UNREAD_AFTER (default 24h). The job runs
+// every DIGEST_INTERVAL (default 1h).
+func StartDigestJob() {
+	unreadAfter := envDuration("DIGEST_UNREAD_AFTER", 24*time.Hour)
+	interval := envDuration("DIGEST_INTERVAL", time.Hour)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sendDigests(unreadAfter)
+		}
+	}()
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("[ERROR] Invalid %s %q, using %s", name, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func sendDigests(unreadAfter time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{
+			"$match": bson.M{
+				"seen":       false,
+				"createdAt":  bson.M{"$lt": time.Now().Add(-unreadAfter)},
+				"digestedAt": bson.M{"$exists": false},
+			},
+		},
+		{
+			"$sort": bson.M{"createdAt": -1},
+		},
+		{
+			"$group": bson.M{
+				"_id":        bson.M{"receiver": "$receiverID", "sender": "$senderID"},
+				"count":      bson.M{"$sum": 1},
+				"snippets":   bson.M{"$push": "$content"},
+				"messageIds": bson.M{"$push": "$_id"},
+			},
+		},
+		{
+			"$group": bson.M{
+				"_id":   "$_id.receiver",
+				"total": bson.M{"$sum": "$count"},
+				"senders": bson.M{"$push": bson.M{
+					"senderId": "$_id.sender",
+					"count":    "$count",
+					// Attachment-only messages have nothing to quote
+					"snippets": bson.M{"$slice": []interface{}{
+						bson.M{"$filter": bson.M{
+							"input": "$snippets",
+							"cond":  bson.M{"$ne": []interface{}{"$$this", ""}},
+						}},
+						digestSnippetsPerSender,
+					}},
+					"messageIds": "$messageIds",
+				}},
+			},
+		},
+	}
+
+	cursor, err := utils.DB.Collection("messages").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("[ERROR] Failed to find unread messages for digests: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var groups []digestGroup
+	if err := cursor.All(ctx, &groups); err != nil {
+		log.Printf("[ERROR] Failed to decode digest groups: %v", err)
+		return
+	}
+
+	for _, group := range groups {
+		if err := sendDigest(ctx, group); err != nil {
+			log.Printf("[ERROR] Failed to send digest to user %s: %v", group.ReceiverID.Hex(), err)
+		}
+	}
+	log.Printf("[INFO] Digest run processed %d users", len(groups))
+}
+
+// digestSenders prepares each sender's section of a digest. Receivers who
+// hide notification previews only get counts, never message content.
+func digestSenders(senders []digestGroupSender, usernames map[primitive.ObjectID]string, hidePreviews bool) []digestSender {
+	result := make([]digestSender, 0, len(senders))
+	for _, s := range senders {
+		var snippets []string
+		if !hidePreviews {
+			for _, snippet := range s.Snippets {
+				if snippet != "" {
+					snippets = append(snippets, truncate(snippet, digestSnippetLength))
+				}
+			}
+		}
+		result = append(result, digestSender{
+			Username: usernames[s.SenderID],
+			Count:    s.Count,
+			Snippets: snippets,
+		})
+	}
+	return result
+}
+
+// sendDigest emails one receiver and marks the included messages digested
+// so they are never sent twice.
+func sendDigest(ctx context.Context, group digestGroup) error {
+	var receiver models.User
+	if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": group.ReceiverID}).Decode(&receiver); err != nil {
+		return err
+	}
+
+	// Opted-out users are marked too, so the job doesn't revisit them
+	if receiver.EmailDigestsDisabled || receiver.Email == "" {
+		return markDigested(ctx, group)
+	}
+
+	senderIDs := make([]primitive.ObjectID, 0, len(group.Senders))
+	for _, s := range group.Senders {
+		senderIDs = append(senderIDs, s.SenderID)
+	}
+	usernames, err := loadUsernames(ctx, senderIDs)
+	if err != nil {
+		return err
+	}
+
+	token, err := utils.GenerateUnsubscribeToken(receiver.ID.Hex())
+	if err != nil {
+		return err
+	}
+
+	data := digestData{
+		Username:       receiver.Username,
+		Total:          group.Total,
+		Senders:        digestSenders(group.Senders, usernames, receiver.HideNotificationPreviews),
+		UnsubscribeURL: appBaseURL() + "/unsubscribe/digest?token=" + url.QueryEscape(token),
+	}
+
+	text, html, err := mailer.Render("digest", data)
+	if err != nil {
+		return err
+	}
+
+	err = mailer.Default.Send(ctx, mailer.Email{
+		To:      receiver.Email,
+		Subject: "You have unread messages",
+		Text:    text,
+		HTML:    html,
+	})
+	if err != nil {
+		return err
+	}
+
+	return markDigested(ctx, group)
+}
+
+func markDigested(ctx context.Context, group digestGroup) error {
+	var ids []primitive.ObjectID
+	for _, s := range group.Senders {
+		ids = append(ids, s.MessageIDs...)
+	}
+
+	_, err := utils.DB.Collection("messages").UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"digestedAt": time.Now()}},
+	)
+	return err
+}
+
+// loadUsernames maps each user ID to its username.
+func loadUsernames(ctx context.Context, userIDs []primitive.ObjectID) (map[primitive.ObjectID]string, error) {
+	cursor, err := utils.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[primitive.ObjectID]string, len(users))
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+	return usernames, nil
+}
+
+// appBaseURL is the public address used in links sent by email.
+func appBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return base
+	}
+	return "http://localhost:8080"
+}
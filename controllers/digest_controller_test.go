@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestDigestSenders(t *testing.T) {
+	sam, alex := primitive.NewObjectID(), primitive.NewObjectID()
+	usernames := map[primitive.ObjectID]string{sam: "sam", alex: "alex"}
+	long := strings.Repeat("a", digestSnippetLength+10)
+	senders := []digestGroupSender{
+		{SenderID: sam, Count: 3, Snippets: []string{"lunch?", "", long}},
+		{SenderID: alex, Count: 1},
+	}
+
+	tests := []struct {
+		name string
+		hide bool
+		want []digestSender
+	}{
+		{"previews", false, []digestSender{
+			{Username: "sam", Count: 3, Snippets: []string{"lunch?", truncate(long, digestSnippetLength)}},
+			{Username: "alex", Count: 1},
+		}},
+		{"previews hidden", true, []digestSender{
+			{Username: "sam", Count: 3},
+			{Username: "alex", Count: 1},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := digestSenders(senders, usernames, tt.hide); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("digestSenders() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
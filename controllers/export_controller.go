@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExportData sends the caller a zip archive of everything stored about them:
+// profile, contacts, blocks, conversation settings, messages and their
+// translations, devices, sessions and uploaded files.
+func ExportData(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var user models.User
+	if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "User not found",
+			Data:         nil,
+		})
+		return
+	}
+
+	contacts := []models.Contact{}
+	blocks := []models.Block{}
+	conversations := []models.ConversationSettings{}
+	messages := []models.Message{}
+	devices := []models.DeviceToken{}
+	sessions := []models.Session{}
+	attachments := []models.Attachment{}
+
+	// Everything is loaded before the response starts so a failure can
+	// still be reported as an error
+	queries := []struct {
+		collection string
+		filter     bson.M
+		out        interface{}
+	}{
+		{"contacts", bson.M{"$or": []bson.M{{"requesterId": userID}, {"addresseeId": userID}}}, &contacts},
+		{"blocks", bson.M{"blockerId": userID}, &blocks},
+		{"conversation_settings", bson.M{"userId": userID}, &conversations},
+		{"messages", bson.M{"$or": []bson.M{{"senderID": userID}, {"receiverID": userID}}}, &messages},
+		{"device_tokens", bson.M{"userId": userID}, &devices},
+		{"sessions", bson.M{"userId": userID}, &sessions},
+		{"attachments", bson.M{"ownerId": userID}, &attachments},
+	}
+	for _, q := range queries {
+		cursor, err := utils.DB.Collection(q.collection).Find(ctx, q.filter, options.Find().SetSort(bson.M{"createdAt": 1}))
+		if err == nil {
+			err = cursor.All(ctx, q.out)
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to export %s of user %s: %v", q.collection, userID.Hex(), err)
+			c.JSON(http.StatusInternalServerError, models.Response{
+				ResponseCode: http.StatusInternalServerError,
+				Message:      "Failed to export data",
+				Data:         nil,
+			})
+			return
+		}
+	}
+
+	messageIDs := make([]primitive.ObjectID, 0, len(messages))
+	for _, message := range messages {
+		messageIDs = append(messageIDs, message.ID)
+	}
+	translations := []models.MessageTranslation{}
+	cursor, err := utils.DB.Collection("message_translations").Find(ctx, bson.M{"messageId": bson.M{"$in": messageIDs}})
+	if err == nil {
+		err = cursor.All(ctx, &translations)
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to export message_translations of user %s: %v", userID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to export data",
+			Data:         nil,
+		})
+		return
+	}
+
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"profile.json", ownProfile(user)},
+		{"contacts.json", contacts},
+		{"blocks.json", blocks},
+		{"conversations.json", conversations},
+		{"messages.json", messages},
+		{"translations.json", translations},
+		{"devices.json", devices},
+		{"sessions.json", sessions},
+		{"attachments.json", attachments},
+	}
+
+	filename := fmt.Sprintf("export-%s-%s.zip", userID.Hex(), time.Now().Format("20060102"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	// From here on the status is sent, so failures can only be logged and
+	// leave a truncated archive
+	zw := zip.NewWriter(c.Writer)
+	for _, f := range files {
+		if err := writeExportJSON(zw, f.name, f.data); err != nil {
+			log.Printf("[ERROR] Failed to write %s to export of user %s: %v", f.name, userID.Hex(), err)
+			return
+		}
+	}
+	for _, attachment := range attachments {
+		if err := writeExportFile(zw, "attachments/"+attachment.Path, filepath.Join(utils.UploadDir(), attachment.Path)); err != nil {
+			log.Printf("[WARN] Skipped attachment %s in export of user %s: %v", attachment.ID.Hex(), userID.Hex(), err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("[ERROR] Failed to finish export of user %s: %v", userID.Hex(), err)
+		return
+	}
+
+	log.Printf("[INFO] Exported data of user %s", userID.Hex())
+}
+
+func writeExportJSON(zw *zip.Writer, name string, data interface{}) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+func writeExportFile(zw *zip.Writer, name, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
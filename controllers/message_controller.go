@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"log"
 	"net/http"
 	"time"
@@ -12,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -31,6 +33,14 @@ func SendMessage(c *gin.Context) {
 		})
 		return
 	}
+	if req.Content == "" && len(req.AttachmentIDs) == 0 {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "A message needs content or attachments",
+			Data:         nil,
+		})
+		return
+	}
 
 	// Convert receiver ID to ObjectID
 	receiverID, err := primitive.ObjectIDFromHex(req.ReceiverID)
@@ -43,9 +53,45 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
+	// Retries carry the same client message ID, either in the body or as an
+	// Idempotency-Key header
+	clientMessageID := req.ClientMessageID
+	if clientMessageID == "" {
+		clientMessageID = c.GetHeader("Idempotency-Key")
+	}
+
 	ctx := context.Background()
+	if clientMessageID != "" {
+		existing, err := findByClientMessageID(ctx, senderID, clientMessageID)
+		if err == nil {
+			c.JSON(http.StatusOK, models.Response{
+				ResponseCode: http.StatusOK,
+				Message:      "Message already sent",
+				Data:         existing,
+			})
+			return
+		}
+		if err != mongo.ErrNoDocuments {
+			c.JSON(http.StatusInternalServerError, models.Response{
+				ResponseCode: http.StatusInternalServerError,
+				Message:      "Failed to send message",
+				Data:         nil,
+			})
+			return
+		}
+	}
+
 	userCollection := utils.DB.Collection("users")
-	userCount, err := userCollection.CountDocuments(ctx, bson.M{"_id": receiverID})
+	var receiver models.User
+	err = userCollection.FindOne(ctx, bson.M{"_id": receiverID}).Decode(&receiver)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Receiver does not exist",
+			Data:         nil,
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.Response{
 			ResponseCode: http.StatusInternalServerError,
@@ -54,10 +100,20 @@ func SendMessage(c *gin.Context) {
 		})
 		return
 	}
-	if userCount == 0 {
+
+	attachments, err := messageAttachments(ctx, senderID, req.AttachmentIDs)
+	if err == errInvalidAttachment {
 		c.JSON(http.StatusBadRequest, models.Response{
 			ResponseCode: http.StatusBadRequest,
-			Message:      "Receiver does not exist",
+			Message:      "Attachments must be files you uploaded",
+			Data:         nil,
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to send message",
 			Data:         nil,
 		})
 		return
@@ -65,16 +121,77 @@ func SendMessage(c *gin.Context) {
 
 	// Create message
 	message := models.Message{
-		ID:         primitive.NewObjectID(),
-		SenderID:   senderID,
-		ReceiverID: receiverID,
-		Content:    req.Content,
-		Seen:       false,
-		CreatedAt:  time.Now(),
+		ID:              primitive.NewObjectID(),
+		ClientMessageID: clientMessageID,
+		SenderID:        senderID,
+		ReceiverID:      receiverID,
+		Content:         req.Content,
+		Attachments:     attachments,
+		Seen:            false,
+		Status:          models.MessageStatusSent,
+		CreatedAt:       time.Now(),
+	}
+
+	blockedByMe, blockedMe, err := blockBetween(ctx, senderID, receiverID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error checking receiver",
+			Data:         nil,
+		})
+		return
+	}
+	if blockedByMe {
+		c.JSON(http.StatusForbidden, models.Response{
+			ResponseCode: http.StatusForbidden,
+			Message:      "Unblock this user to send them messages",
+			Data:         nil,
+		})
+		return
+	}
+	if blockedMe {
+		// Answer exactly like a normal send so the block isn't revealed;
+		// the message is dropped
+		c.JSON(http.StatusOK, models.Response{
+			ResponseCode: http.StatusOK,
+			Message:      "Message sent successfully",
+			Data:         message,
+		})
+		return
+	}
+
+	allowed, err := canMessage(ctx, senderID, receiver)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error checking receiver",
+			Data:         nil,
+		})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, models.Response{
+			ResponseCode: http.StatusForbidden,
+			Message:      "This user only accepts messages from contacts",
+			Data:         nil,
+		})
+		return
 	}
 
 	// Save to MongoDB
 	_, err = utils.DB.Collection("messages").InsertOne(ctx, message)
+	if mongo.IsDuplicateKeyError(err) && clientMessageID != "" {
+		// A concurrent retry won the race; return what it stored
+		existing, findErr := findByClientMessageID(ctx, senderID, clientMessageID)
+		if findErr == nil {
+			c.JSON(http.StatusOK, models.Response{
+				ResponseCode: http.StatusOK,
+				Message:      "Message already sent",
+				Data:         existing,
+			})
+			return
+		}
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.Response{
 			ResponseCode: http.StatusInternalServerError,
@@ -84,18 +201,11 @@ func SendMessage(c *gin.Context) {
 		return
 	}
 
-	// Trigger Pusher event
-	eventData := gin.H{
-		"message": message,
-		"type":    "new-message",
-	}
+	deliverMessage(ctx, message, deviceID(c))
 
-	// Trigger to both sender and receiver channels
-	channelName := "private-chat-" + receiverID.Hex()
-	err = utils.PusherClient.Trigger(channelName, "message", eventData)
-	if err != nil {
-		log.Printf("[ERROR] Failed to trigger Pusher event: %v", err)
-		// Don't return error to client as message is already saved
+	// Bots answer in the background through the same path
+	if receiver.IsBot {
+		replyAsBot(receiver, message)
 	}
 
 	c.JSON(http.StatusOK, models.Response{
@@ -105,6 +215,93 @@ func SendMessage(c *gin.Context) {
 	})
 }
 
+// errInvalidAttachment is returned for an attachment ID that doesn't name
+// one of the sender's uploads.
+var errInvalidAttachment = errors.New("invalid attachment")
+
+// messageAttachments loads the sender's uploads to send with a message, in
+// the order given.
+func messageAttachments(ctx context.Context, senderID primitive.ObjectID, hexIDs []string) ([]models.Attachment, error) {
+	if len(hexIDs) == 0 {
+		return nil, nil
+	}
+	ids := make([]primitive.ObjectID, 0, len(hexIDs))
+	for _, hex := range hexIDs {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return nil, errInvalidAttachment
+		}
+		ids = append(ids, id)
+	}
+
+	cursor, err := utils.DB.Collection("attachments").Find(ctx, bson.M{
+		"_id":     bson.M{"$in": ids},
+		"ownerId": senderID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var found []models.Attachment
+	if err := cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	byID := make(map[primitive.ObjectID]models.Attachment, len(found))
+	for _, attachment := range found {
+		byID[attachment.ID] = attachment
+	}
+
+	attachments := make([]models.Attachment, 0, len(ids))
+	for _, id := range ids {
+		attachment, ok := byID[id]
+		if !ok {
+			return nil, errInvalidAttachment
+		}
+		attachments = append(attachments, attachment)
+	}
+	return attachments, nil
+}
+
+// deliverMessage does everything that follows saving a new message: it
+// unarchives the conversation, clears the typing indicator, publishes the
+// message to both users' devices and queues push notifications.
+// originDeviceID is the sending device, empty when there is none.
+func deliverMessage(ctx context.Context, message models.Message, originDeviceID string) {
+	senderID, receiverID := message.SenderID, message.ReceiverID
+
+	// A new message brings an archived conversation back
+	if err := unarchiveConversation(ctx, senderID, receiverID); err != nil {
+		log.Printf("[ERROR] Failed to unarchive conversation: %v", err)
+	}
+
+	// The message replaces any typing indicator the receiver is showing
+	stopTyping(senderID, receiverID)
+
+	// Make it searchable by meaning
+	queueEmbedding()
+
+	// The receiver gets the message; the sender's devices also get the
+	// device that sent it, so it can ignore its own echo
+	err := utils.PusherClient.Trigger(utils.UserChannel(receiverID.Hex()), "message", gin.H{
+		"message": message,
+		"type":    "new-message",
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to trigger Pusher event: %v", err)
+		// Don't return error to client as message is already saved
+	}
+	err = utils.PusherClient.Trigger(utils.UserChannel(senderID.Hex()), "message", gin.H{
+		"message":        message,
+		"type":           "new-message",
+		"originDeviceId": originDeviceID,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to sync message to sender's devices: %v", err)
+	}
+
+	// Notify the receiver's devices if they aren't connected
+	queuePush(message)
+}
+
 // Get messages between logged-in user and another user
 func GetMessages(c *gin.Context) {
 	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
@@ -114,18 +311,11 @@ func GetMessages(c *gin.Context) {
 		return
 	}
 
-	filter := bson.M{
-		"$or": []bson.M{
-			{"senderId": currentUser, "receiverId": otherID},
-			{"senderId": otherID, "receiverId": currentUser},
-		},
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
 	opts := options.Find().SetSort(bson.M{"createdAt": 1}) // sort by time
-	cursor, err := utils.DB.Collection("messages").Find(ctx, filter, opts)
+	cursor, err := utils.DB.Collection("messages").Find(ctx, conversationFilter(currentUser, otherID), opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch messages"})
 		return
@@ -137,6 +327,8 @@ func GetMessages(c *gin.Context) {
 		return
 	}
 
+	attachTranslations(ctx, currentUser, otherID, messages)
+
 	c.JSON(http.StatusOK, models.Response{
 		ResponseCode: http.StatusOK,
 		Message:      "Messages fetched successfully",
@@ -160,13 +352,38 @@ func MarkMessagesSeen(c *gin.Context) {
 		"seen":       false,
 	}
 
-	update := bson.M{
-		"$set": bson.M{"seen": true},
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
+	// Collect the messages first so the sender can be told exactly which
+	// ones were read
+	unseen, err := findMessages(ctx, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to mark messages as seen: " + err.Error(),
+			Data:         nil,
+		})
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(unseen))
+	for _, m := range unseen {
+		ids = append(ids, m.ID)
+	}
+	filter["_id"] = bson.M{"$in": ids}
+
+	// Messages read without an ack are delivered at the same moment
+	now := time.Now()
+	update := []bson.M{
+		{"$set": bson.M{
+			"seen":        true,
+			"status":      models.MessageStatusRead,
+			"readAt":      now,
+			"deliveredAt": bson.M{"$ifNull": []interface{}{"$deliveredAt", now}},
+		}},
+	}
+
 	result, err := utils.DB.Collection("messages").UpdateMany(ctx, filter, update)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.Response{
@@ -177,6 +394,19 @@ func MarkMessagesSeen(c *gin.Context) {
 		return
 	}
 
+	if len(unseen) > 0 {
+		lastRead := unseen[len(unseen)-1]
+		if err := advanceReadPointer(ctx, currentUser, otherID, lastRead.ID, now); err != nil {
+			log.Printf("[ERROR] Failed to update read pointer: %v", err)
+		}
+		sendReceipts(unseen, models.MessageStatusRead, now)
+		syncOwnDevices(c, currentUser, "messages-seen", gin.H{
+			"userId":     otherID.Hex(),
+			"messageIds": ids,
+			"readAt":     now,
+		})
+	}
+
 	// Add response with modified count for debugging
 	c.JSON(http.StatusOK, models.Response{
 		ResponseCode: http.StatusOK,
@@ -201,18 +431,12 @@ func DeleteChatHistory(c *gin.Context) {
 		return
 	}
 
-	filter := bson.M{
-		"$or": []bson.M{
-			{"senderId": currentUser, "receiverId": otherID},
-			{"senderId": otherID, "receiverId": currentUser},
-		},
-	}
-
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	result, err := utils.DB.Collection("messages").DeleteMany(ctx, filter)
+	deleted, err := deleteConversation(ctx, currentUser, otherID)
 	if err != nil {
+		log.Printf("[ERROR] Failed to delete chat history for user %s: %v", currentUser.Hex(), err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			ResponseCode: http.StatusInternalServerError,
 			Message:      "Failed to delete chat history",
@@ -221,16 +445,53 @@ func DeleteChatHistory(c *gin.Context) {
 		return
 	}
 
+	syncOwnDevices(c, currentUser, "chat-deleted", gin.H{
+		"userId": otherID.Hex(),
+	})
+
 	c.JSON(http.StatusOK, models.Response{
 		ResponseCode: http.StatusOK,
 		Message:      "Chat history deleted successfully",
 		Data: gin.H{
-			"deletedCount": result.DeletedCount,
-			"matchedCount": result.DeletedCount,
+			"deletedCount": deleted,
+			"matchedCount": deleted,
 		},
 	})
 }
 
+// deleteConversation deletes the messages between a and b along with their
+// stored translations, and returns how many messages went.
+func deleteConversation(ctx context.Context, a, b primitive.ObjectID) (int64, error) {
+	messages := utils.DB.Collection("messages")
+	filter := conversationFilter(a, b)
+	ids, err := messages.Distinct(ctx, "_id", filter)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) > 0 {
+		_, err = utils.DB.Collection("message_translations").DeleteMany(ctx, bson.M{"messageId": bson.M{"$in": ids}})
+		if err != nil {
+			return 0, err
+		}
+	}
+	result, err := messages.DeleteMany(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// conversationFilter matches the messages exchanged between a and b in
+// either direction.
+func conversationFilter(a, b primitive.ObjectID) bson.M {
+	return bson.M{
+		"$or": []bson.M{
+			{"senderID": a, "receiverID": b},
+			{"senderID": b, "receiverID": a},
+		},
+	}
+}
+
 func GetChatByID(c *gin.Context) {
 	chatID := c.Query("id")
 	if chatID == "" {
@@ -354,3 +615,28 @@ func GetChatByID(c *gin.Context) {
 		Data:         results[0],
 	})
 }
+
+// deviceID identifies the client device or session that made the request, so
+// it can ignore the echo of its own changes.
+func deviceID(c *gin.Context) string {
+	return c.GetHeader("X-Device-ID")
+}
+
+// syncOwnDevices sends a change made by the user to all of their own
+// devices, tagged with the device that made it.
+func syncOwnDevices(c *gin.Context, userID primitive.ObjectID, event string, data gin.H) {
+	data["originDeviceId"] = deviceID(c)
+	err := utils.PusherClient.Trigger(utils.UserChannel(userID.Hex()), event, data)
+	if err != nil {
+		log.Printf("[ERROR] Failed to sync %s to user %s: %v", event, userID.Hex(), err)
+	}
+}
+
+func findByClientMessageID(ctx context.Context, senderID primitive.ObjectID, clientMessageID string) (models.Message, error) {
+	var message models.Message
+	err := utils.DB.Collection("messages").FindOne(ctx, bson.M{
+		"senderID":        senderID,
+		"clientMessageId": clientMessageID,
+	}).Decode(&message)
+	return message, err
+}
@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sajanIocod/chat_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestConversationFilter(t *testing.T) {
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	want := bson.M{
+		"$or": []bson.M{
+			{"senderID": a, "receiverID": b},
+			{"senderID": b, "receiverID": a},
+		},
+	}
+	if got := conversationFilter(a, b); !reflect.DeepEqual(got, want) {
+		t.Errorf("conversationFilter() = %v, want %v", got, want)
+	}
+
+	// The filter must use the field names the messages are stored under
+	raw, err := bson.Marshal(models.Message{SenderID: a, ReceiverID: b})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stored bson.M
+	if err := bson.Unmarshal(raw, &stored); err != nil {
+		t.Fatal(err)
+	}
+	for field := range want["$or"].([]bson.M)[0] {
+		if _, ok := stored[field]; !ok {
+			t.Errorf("messages are not stored with a %q field", field)
+		}
+	}
+}
@@ -2,154 +2,458 @@ package controllers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/llm"
 	"github.com/sajanIocod/chat_backend/models"
-	"google.golang.org/genai"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+const (
+	// suggestionContextMessages is how many recent messages are given to
+	// the model as context for reply suggestions.
+	suggestionContextMessages = 20
+	// defaultSuggestionCount is how many suggestions are made when the
+	// request doesn't say.
+	defaultSuggestionCount = 3
+)
+
+// suggestionTones describes each tone to the model
+var suggestionTones = map[string]string{
+	models.ToneCasual:     "relaxed and friendly",
+	models.ToneFormal:     "polite and formal",
+	models.ToneBrief:      "as short as possible, a few words each",
+	models.ToneEmpathetic: "warm, supportive and understanding",
+}
+
+// errSuggestionsCancelled stops a suggestion stream when its user starts
+// typing in the conversation.
+var errSuggestionsCancelled = errors.New("suggestions cancelled by typing")
+
+// contextMessage is one message of a conversation as the model sees it.
+type contextMessage struct {
+	// Role is "me" for the user asking and "them" for the other member
+	Role    string
+	Name    string
+	Content string
+	At      time.Time
+}
+
+// suggestionOutput is the JSON the model must answer with.
+type suggestionOutput struct {
+	Language    string   `json:"language"`
+	Suggestions []string `json:"suggestions"`
+}
+
+// suggestionStream is a streaming request in progress, kept so typing in the
+// conversation can cancel it.
+type suggestionStream struct {
+	cancel context.CancelCauseFunc
+}
+
+var (
+	suggestionMu      sync.Mutex
+	suggestionStreams = map[string]*suggestionStream{}
+)
+
+// GetReplySuggestions suggests replies for the caller in a conversation,
+// using the latest messages stored for it. Replies are in the language of
+// the conversation, which is returned with them.
 func GetReplySuggestions(c *gin.Context) {
-	var body struct {
-		History []models.Message `json:"history" binding:"required,dive"`
+	// Create context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 45*time.Second)
+	defer cancel()
+	ctx = llm.WithUser(ctx, c.GetString("userID"))
+
+	_, req, tone, ok := suggestionRequest(ctx, c)
+	if !ok {
+		return
 	}
 
-	// Parse request body
-	if err := c.ShouldBindJSON(&body); err != nil {
-		log.Printf("[ERROR] Invalid request body: %v", err)
-		c.JSON(http.StatusBadRequest, models.Response{
-			ResponseCode: http.StatusBadRequest,
-			Message:      "Invalid request: History array is required with valid messages",
+	// Output that doesn't match the schema is retried by GenerateJSON
+	var output suggestionOutput
+	_, err := llm.GenerateJSON(ctx, llm.FeatureSuggestions, req, &output)
+	if errors.Is(err, llm.ErrNotConfigured) {
+		log.Printf("[ERROR] No LLM provider configured for suggestions")
+		c.JSON(http.StatusServiceUnavailable, models.Response{
+			ResponseCode: http.StatusServiceUnavailable,
+			Message:      "Suggestions are not available",
 			Data:         nil,
 		})
 		return
 	}
-
-	// Validate history
-	if len(body.History) == 0 {
-		log.Printf("[ERROR] Empty chat history received")
-		c.JSON(http.StatusBadRequest, models.Response{
-			ResponseCode: http.StatusBadRequest,
-			Message:      "Chat history cannot be empty",
+	if aiQuotaExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to generate suggestions: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to generate suggestions",
 			Data:         nil,
 		})
 		return
 	}
 
-	// Get API key
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	if apiKey == "" {
-		log.Printf("[ERROR] GEMINI_API_KEY environment variable not set")
+	suggestions := []string{}
+	for _, suggestion := range output.Suggestions {
+		if suggestion = strings.TrimSpace(suggestion); suggestion != "" {
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	if len(suggestions) == 0 {
+		log.Printf("[ERROR] No suggestions in model output")
 		c.JSON(http.StatusInternalServerError, models.Response{
 			ResponseCode: http.StatusInternalServerError,
-			Message:      "Missing Gemini API key",
+			Message:      "No suggestions found in response",
 			Data:         nil,
 		})
 		return
 	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	log.Printf("[INFO] Successfully generated %d suggestions", len(suggestions))
 
-	// Initialize Gemini client
-	log.Printf("[INFO] Initializing Gemini client")
-	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
+	// Return suggestions
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Suggestions generated successfully",
+		Data: gin.H{
+			"suggestions": suggestions,
+			"language":    output.Language,
+			"tone":        tone,
+		},
 	})
-	if err != nil {
-		log.Printf("[ERROR] Failed to create Gemini client: %v", err)
-		c.JSON(http.StatusInternalServerError, models.Response{
-			ResponseCode: http.StatusInternalServerError,
-			Message:      "Failed to initialize Gemini client",
-			Data:         nil,
-		})
+}
+
+// StreamReplySuggestions is GetReplySuggestions over server-sent events.
+// Each suggestion is sent as a "suggestion" event as soon as the model has
+// finished it, followed by "done" with the conversation's language. The
+// stream stops when the client disconnects, and ends with "cancelled" when
+// the caller starts typing in the conversation.
+func StreamReplySuggestions(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	ctx, cancel := context.WithCancelCause(c.Request.Context())
+	defer cancel(nil)
+	usageCtx := llm.WithUser(ctx, userID.Hex())
+
+	peerID, req, tone, ok := suggestionRequest(ctx, c)
+	if !ok {
 		return
 	}
 
-	// Format chat history for prompt
-	var formattedHistory strings.Builder
-	for _, msg := range body.History {
-		role := "User"
-		if msg.SenderID == msg.ReceiverID {
-			role = "Bot"
+	key := typingKey(userID, peerID)
+	stream := startSuggestionStream(key, cancel)
+	defer endSuggestionStream(key, stream)
+
+	// The stream starts with the first event, so a call refused before any
+	// output can still be answered with a plain error
+	started := false
+	emit := func(event string, data interface{}) {
+		if !started {
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("X-Accel-Buffering", "no")
+			c.Status(http.StatusOK)
+			started = true
 		}
-		formattedHistory.WriteString(role + ": " + msg.Content + "\n")
+		c.SSEvent(event, data)
+		c.Writer.Flush()
 	}
-	prompt := "Based on this chat history:\n" + formattedHistory.String() +
-		"\n\nProvide exactly 3 short, natural reply suggestions. Format them as a numbered list (1., 2., 3.)"
-	// Prepare model and prompt
-	result, err := client.Models.GenerateContent(ctx,
-		"gemini-2.0-flash",
-		genai.Text(prompt),
-		nil)
 
-	log.Printf("[INFO] Sending prompt to Gemini API with model: gemini-1.0-pro")
-	// Configure generation parameters
+	// The JSON arrives in arbitrary pieces; a suggestion is complete once
+	// its closing quote has arrived
+	count := 0
+	var text strings.Builder
+	result, err := llm.Stream(usageCtx, llm.FeatureSuggestions, req, func(piece string) error {
+		text.WriteString(piece)
+		suggestions := completedSuggestions(text.String())
+		for ; count < len(suggestions); count++ {
+			emit("suggestion", gin.H{"index": count, "text": suggestions[count]})
+		}
+		return nil
+	})
+
+	// Streamed output can't be retried, so it is only checked at the end
+	var output suggestionOutput
+	if err == nil {
+		if err = llm.DecodeJSON(result.Text, req.Schema, &output); err != nil && count > 0 {
+			log.Printf("[WARN] Streamed suggestions don't match the schema: %v", err)
+			err = nil
+		}
+	}
 
-	// Generate content
+	switch {
+	case errors.Is(context.Cause(ctx), errSuggestionsCancelled):
+		emit("cancelled", gin.H{"count": count})
+	case c.Request.Context().Err() != nil:
+		// The client has gone; there is no one to tell
+		log.Printf("[INFO] Suggestion stream for user %s closed by client", userID.Hex())
+	case !started && aiQuotaExceeded(c, err):
+	case errors.Is(err, llm.ErrNotConfigured):
+		emit("error", gin.H{"message": "Suggestions are not available"})
+	case err != nil:
+		log.Printf("[ERROR] Failed to stream suggestions: %v", err)
+		emit("error", gin.H{"message": "Failed to generate suggestions"})
+	default:
+		emit("done", gin.H{"count": count, "language": output.Language, "tone": tone})
+	}
+}
+
+// suggestionRequest reads the suggestion request and builds the model
+// request from the conversation, answering the request itself when it
+// can't.
+func suggestionRequest(ctx context.Context, c *gin.Context) (primitive.ObjectID, llm.Request, string, bool) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.SuggestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		log.Printf("[ERROR] Invalid request body: %v", err)
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request: conversationId is required, count must be 1-5 and tone one of casual, formal, brief or empathetic",
+			Data:         nil,
+		})
+		return primitive.NilObjectID, llm.Request{}, "", false
+	}
+	peerID, err := primitive.ObjectIDFromHex(req.ConversationID)
+	if err != nil || peerID == currentUser {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid conversation ID",
+			Data:         nil,
+		})
+		return primitive.NilObjectID, llm.Request{}, "", false
+	}
+	if req.Count == 0 {
+		req.Count = defaultSuggestionCount
+	}
+	if req.Tone == "" {
+		req.Tone = models.ToneCasual
+	}
+	history, err := loadConversationContext(ctx, currentUser, peerID, suggestionContextMessages)
 	if err != nil {
-		log.Printf("[ERROR] Failed to generate content: %v", err)
+		log.Printf("[ERROR] Failed to load conversation for suggestions: %v", err)
 		c.JSON(http.StatusInternalServerError, models.Response{
 			ResponseCode: http.StatusInternalServerError,
-			Message:      "Failed to generate suggestions",
+			Message:      "Failed to load conversation",
 			Data:         nil,
 		})
-		return
+		return primitive.NilObjectID, llm.Request{}, "", false
 	}
-
-	if result.Text() == "" {
-		log.Printf("[ERROR] No text content found in response")
-		c.JSON(http.StatusInternalServerError, models.Response{
-			ResponseCode: http.StatusInternalServerError,
-			Message:      "No text content in response",
+	if history == nil {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "Conversation not found",
 			Data:         nil,
 		})
-		return
+		return primitive.NilObjectID, llm.Request{}, "", false
 	}
 
-	// Extract suggestions
-	suggestions := []string{}
-	lines := strings.Split(result.Text(), "\n")
+	// Format chat history for prompt
+	var formattedHistory strings.Builder
+	formattedHistory.WriteString("Chat history:\n")
+	for _, msg := range history {
+		formattedHistory.WriteString(msg.Role + " (" + msg.Name + "): " + msg.Content + "\n")
+	}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		// Remove number prefixes and clean up
-		for _, prefix := range []string{"1.", "2.", "3.", "1)", "2)", "3)"} {
-			line = strings.TrimPrefix(line, prefix)
+	system := fmt.Sprintf("You suggest replies for a chat app user, shown as \"me\" in the chat history; "+
+		"\"them\" is the person they are talking to. Write %d different replies \"me\" could send next. "+
+		"Make them %s. Write them in the language the conversation is in, and give that language as an "+
+		"ISO 639-1 code. Each reply must be ready to send as is, without numbering, quotes or commentary.",
+		req.Count, suggestionTones[req.Tone])
+
+	return peerID, llm.Request{
+		System:   system,
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: formattedHistory.String()}},
+		Schema:   suggestionSchema(req.Count),
+	}, req.Tone, true
+}
+
+// suggestionSchema is the JSON the model must answer with: the language
+// code and exactly count suggestions.
+func suggestionSchema(count int) *llm.Schema {
+	closed := false
+	return &llm.Schema{
+		Type: llm.TypeObject,
+		Properties: map[string]*llm.Schema{
+			"language": {
+				Type:        llm.TypeString,
+				Description: "ISO 639-1 code of the conversation's language",
+			},
+			"suggestions": {
+				Type:     llm.TypeArray,
+				Items:    &llm.Schema{Type: llm.TypeString},
+				MinItems: &count,
+				MaxItems: &count,
+			},
+		},
+		Required:             []string{"language", "suggestions"},
+		AdditionalProperties: &closed,
+	}
+}
+
+// completedSuggestions returns the suggestions whose strings are complete
+// in a prefix of the model's JSON output.
+func completedSuggestions(partial string) []string {
+	// Some models fence their JSON even when asked not to
+	partial = strings.TrimSpace(partial)
+	partial = strings.TrimPrefix(partial, "```json")
+	partial = strings.TrimPrefix(partial, "```")
+
+	dec := json.NewDecoder(strings.NewReader(partial))
+	var suggestions []string
+	depth := 0
+	inSuggestions := false
+	key := ""
+	expectKey := false
+	for {
+		token, err := dec.Token()
+		if err != nil {
+			// The rest hasn't arrived yet
+			return suggestions
 		}
-		line = strings.TrimSpace(line)
-		if line != "" {
-			suggestions = append(suggestions, line)
+		switch t := token.(type) {
+		case json.Delim:
+			switch t {
+			case '{':
+				depth++
+				expectKey = true
+			case '}':
+				depth--
+				expectKey = true
+			case '[':
+				inSuggestions = depth == 1 && key == "suggestions"
+			case ']':
+				inSuggestions = false
+				expectKey = true
+			}
+		case string:
+			switch {
+			case inSuggestions:
+				if s := strings.TrimSpace(t); s != "" {
+					suggestions = append(suggestions, s)
+				}
+			case expectKey && depth == 1:
+				key = t
+				expectKey = false
+			default:
+				expectKey = true
+			}
+		default:
+			expectKey = true
 		}
 	}
+}
 
-	if len(suggestions) == 0 {
-		log.Printf("[ERROR] No suggestions extracted from response")
-		c.JSON(http.StatusInternalServerError, models.Response{
-			ResponseCode: http.StatusInternalServerError,
-			Message:      "No suggestions found in response",
-			Data:         nil,
-		})
-		return
+// startSuggestionStream registers a stream for a user and conversation,
+// cancelling any earlier one still running for it.
+func startSuggestionStream(key string, cancel context.CancelCauseFunc) *suggestionStream {
+	stream := &suggestionStream{cancel: cancel}
+
+	suggestionMu.Lock()
+	defer suggestionMu.Unlock()
+	if previous, ok := suggestionStreams[key]; ok {
+		previous.cancel(errSuggestionsCancelled)
 	}
+	suggestionStreams[key] = stream
+	return stream
+}
 
-	log.Printf("[INFO] Successfully generated %d suggestions", len(suggestions))
+func endSuggestionStream(key string, stream *suggestionStream) {
+	suggestionMu.Lock()
+	defer suggestionMu.Unlock()
+	// A newer stream may have replaced this one
+	if suggestionStreams[key] == stream {
+		delete(suggestionStreams, key)
+	}
+}
 
-	// Return suggestions
-	c.JSON(http.StatusOK, models.Response{
-		ResponseCode: http.StatusOK,
-		Message:      "Suggestions generated successfully",
-		Data: gin.H{
-			"suggestions": suggestions,
+// cancelSuggestionStream stops the user's suggestion stream for the
+// conversation with peerID, if one is running.
+func cancelSuggestionStream(userID, peerID primitive.ObjectID) {
+	suggestionMu.Lock()
+	defer suggestionMu.Unlock()
+	if stream, ok := suggestionStreams[typingKey(userID, peerID)]; ok {
+		stream.cancel(errSuggestionsCancelled)
+	}
+}
+
+// loadConversationContext returns the latest messages between the user and
+// the peer, oldest first. It returns nil when the user has no conversation
+// with the peer, including when either has blocked the other.
+func loadConversationContext(ctx context.Context, userID, peerID primitive.ObjectID, limit int64) ([]contextMessage, error) {
+	blockedByMe, blockedMe, err := blockBetween(ctx, userID, peerID)
+	if err != nil {
+		return nil, err
+	}
+	if blockedByMe || blockedMe {
+		return nil, nil
+	}
+
+	cursor, err := utils.DB.Collection("messages").Find(ctx,
+		bson.M{
+			"$or": []bson.M{
+				{"senderID": userID, "receiverID": peerID},
+				{"senderID": peerID, "receiverID": userID},
+			},
 		},
-	})
+		options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(limit),
+	)
+	if err != nil {
+		return nil, err
+	}
+	var messages []models.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, nil
+	}
+
+	// Newest first from the query, oldest first for the model
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return contextMessages(ctx, userID, peerID, messages)
+}
+
+// contextMessages labels messages between the user and the peer with roles
+// and display names.
+func contextMessages(ctx context.Context, userID, peerID primitive.ObjectID, messages []models.Message) ([]contextMessage, error) {
+	var me, them models.User
+	if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&me); err != nil {
+		return nil, err
+	}
+	// The peer may have deleted their account
+	if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": peerID}).Decode(&them); err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	theirName := displayName(them)
+	if theirName == "" {
+		theirName = "Deleted account"
+	}
+
+	history := make([]contextMessage, 0, len(messages))
+	for _, message := range messages {
+		msg := contextMessage{Role: "them", Name: theirName, Content: message.Content, At: message.CreatedAt}
+		if message.SenderID == userID {
+			msg.Role = "me"
+			msg.Name = displayName(me)
+		}
+		history = append(history, msg)
+	}
+	return history, nil
 }
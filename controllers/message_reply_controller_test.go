@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompletedSuggestions(t *testing.T) {
+	tests := []struct {
+		name    string
+		partial string
+		want    []string
+	}{
+		{"empty", ``, nil},
+		{"before the array", `{"language": "en", "sugg`, nil},
+		{"unfinished string", `{"language": "en", "suggestions": ["Sure, see you`, nil},
+		{"one complete", `{"language": "en", "suggestions": ["Sure!", "See y`, []string{"Sure!"}},
+		{"all complete", `{"language": "en", "suggestions": ["Sure!", "See you"]}`, []string{"Sure!", "See you"}},
+		{"language after", `{"suggestions": ["Sure!"], "language": "en"}`, []string{"Sure!"}},
+		{"blank suggestion", `{"suggestions": ["  ", "Hi"]`, []string{"Hi"}},
+		{"escaped quote", `{"suggestions": ["Say \"hi\"", "x`, []string{`Say "hi"`}},
+		{"code fence", "```json\n{\"suggestions\": [\"Sure!\"", []string{"Sure!"}},
+		{"nested array elsewhere", `{"other": {"suggestions": ["no"]}, "suggestions": ["yes"]}`, []string{"yes"}},
+		{"value named suggestions", `{"language": "suggestions", "x": ["no"]}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := completedSuggestions(tt.partial); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("completedSuggestions(%q) = %q, want %q", tt.partial, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// snippetLength is about how many characters of a message a snippet
+	// shows.
+	snippetLength = 160
+	// snippetLead is how many characters are shown before the first match.
+	snippetLead = 40
+)
+
+// SearchMessages finds the caller's messages containing the words in q,
+// across all their conversations or one (?conversationId=). Results can be
+// narrowed to a sender (?senderId=), a time range (?from=, ?to=, RFC 3339)
+// and to messages with or without attachments (?hasAttachment=), and are
+// sorted by relevance or, with ?sort=newest, by time. Each comes with a
+// highlighted snippet and optionally ?context= messages either side.
+func SearchMessages(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	query := strings.TrimSpace(c.Query("q"))
+	terms := searchTerms(query)
+	if len(terms) == 0 || len(query) > semanticSearchMaxQuery {
+		profileBadRequest(c, "q is required and must be at most 1000 characters")
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 50 {
+		limit = 20
+	}
+	around, _ := strconv.Atoi(c.DefaultQuery("context", "0"))
+	if around < 0 || around > 10 {
+		around = 0
+	}
+	newest := c.Query("sort") == "newest"
+
+	// Only messages the caller sent or received are ever searched
+	filter := bson.M{
+		"$text": bson.M{"$search": query},
+		"$or":   []bson.M{{"senderID": currentUser}, {"receiverID": currentUser}},
+	}
+	if value := c.Query("conversationId"); value != "" {
+		peerID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			profileBadRequest(c, "Invalid conversation ID")
+			return
+		}
+		filter["$or"] = []bson.M{
+			{"senderID": currentUser, "receiverID": peerID},
+			{"senderID": peerID, "receiverID": currentUser},
+		}
+	}
+	if value := c.Query("senderId"); value != "" {
+		senderID, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			profileBadRequest(c, "Invalid sender ID")
+			return
+		}
+		filter["senderID"] = senderID
+	}
+	if value := c.Query("hasAttachment"); value != "" {
+		hasAttachment, err := strconv.ParseBool(value)
+		if err != nil {
+			profileBadRequest(c, "hasAttachment must be true or false")
+			return
+		}
+		filter["attachments.0"] = bson.M{"$exists": hasAttachment}
+	}
+	createdAt := bson.M{}
+	for param, operator := range map[string]string{"from": "$gte", "to": "$lte"} {
+		if value := c.Query(param); value != "" {
+			at, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				profileBadRequest(c, "from and to must be RFC 3339 times")
+				return
+			}
+			createdAt[operator] = at
+		}
+	}
+	if len(createdAt) > 0 {
+		filter["createdAt"] = createdAt
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	total, err := utils.DB.Collection("messages").CountDocuments(ctx, filter)
+	if err != nil {
+		messageSearchError(c, err)
+		return
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	sort := bson.D{{Key: "score", Value: score}, {Key: "createdAt", Value: -1}}
+	if newest {
+		sort = bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}
+	}
+	cursor, err := utils.DB.Collection("messages").Find(ctx, filter, options.Find().
+		SetProjection(bson.M{"score": score}).
+		SetSort(sort).
+		SetSkip(int64((page-1)*limit)).
+		SetLimit(int64(limit)),
+	)
+	if err != nil {
+		messageSearchError(c, err)
+		return
+	}
+	var found []struct {
+		models.Message `bson:",inline"`
+		Score          float64 `bson:"score"`
+	}
+	if err := cursor.All(ctx, &found); err != nil {
+		messageSearchError(c, err)
+		return
+	}
+
+	messages := make([]models.Message, 0, len(found))
+	for _, f := range found {
+		messages = append(messages, f.Message)
+	}
+	results, err := searchResults(ctx, currentUser, messages, around)
+	if err != nil {
+		messageSearchError(c, err)
+		return
+	}
+	for i := range results {
+		results[i].Score = found[i].Score
+		results[i].Snippet = highlightSnippet(results[i].Message.Content, terms)
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Search completed successfully",
+		Data: gin.H{
+			"query":   query,
+			"results": results,
+			"pagination": gin.H{
+				"page":    page,
+				"limit":   limit,
+				"total":   total,
+				"hasMore": int64(page*limit) < total,
+			},
+		},
+	})
+}
+
+func messageSearchError(c *gin.Context, err error) {
+	log.Printf("[ERROR] Message search failed: %v", err)
+	c.JSON(http.StatusInternalServerError, models.Response{
+		ResponseCode: http.StatusInternalServerError,
+		Message:      "Failed to search messages",
+		Data:         nil,
+	})
+}
+
+// searchTerms returns the lower-cased words and "quoted phrases" of a text
+// search, leaving out -excluded words as MongoDB does.
+func searchTerms(query string) []string {
+	var terms []string
+	parts := strings.Split(query, "\"")
+	for i, part := range parts {
+		// Odd parts were between quotes
+		if i%2 == 1 {
+			if phrase := strings.Join(strings.Fields(strings.ToLower(part)), " "); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			if strings.HasPrefix(word, "-") {
+				continue
+			}
+			word = strings.ToLower(strings.TrimFunc(word, func(r rune) bool {
+				return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+			}))
+			if word != "" {
+				terms = append(terms, word)
+			}
+		}
+	}
+	return terms
+}
+
+// highlightSnippet cuts content down to about snippetLength characters
+// starting a little before the first match, and marks every whole-word
+// match of the terms in it.
+func highlightSnippet(content string, terms []string) string {
+	runes := []rune(content)
+	lower := make([]rune, len(runes))
+	for i, r := range runes {
+		lower[i] = unicode.ToLower(r)
+	}
+	isWord := func(i int) bool {
+		return i >= 0 && i < len(lower) && (unicode.IsLetter(lower[i]) || unicode.IsNumber(lower[i]))
+	}
+
+	// marked[i] is true for characters inside a match
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		for start := 0; start+len(t) <= len(lower); start++ {
+			if isWord(start-1) || isWord(start+len(t)) || string(lower[start:start+len(t)]) != term {
+				continue
+			}
+			for i := start; i < start+len(t); i++ {
+				marked[i] = true
+			}
+			if first == -1 || start < first {
+				first = start
+			}
+		}
+	}
+
+	start := 0
+	if first > snippetLead {
+		start = first - snippetLead
+	}
+	end := start + snippetLength
+	if end > len(runes) {
+		// Show more before the match when the message ends soon after it
+		end = len(runes)
+		start = max(0, end-snippetLength)
+	}
+
+	var snippet strings.Builder
+	if start > 0 {
+		snippet.WriteString("…")
+	}
+	for i := start; i < end; {
+		j := i
+		for j < end && marked[j] == marked[i] {
+			j++
+		}
+		text := html.EscapeString(string(runes[i:j]))
+		if marked[i] {
+			text = "<mark>" + text + "</mark>"
+		}
+		snippet.WriteString(text)
+		i = j
+	}
+	if end < len(runes) {
+		snippet.WriteString("…")
+	}
+	return snippet.String()
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", nil},
+		{"Hello", []string{"hello"}},
+		{"lunch, tomorrow?", []string{"lunch", "tomorrow"}},
+		{"lunch -pizza", []string{"lunch"}},
+		{`"see you  Soon" later`, []string{"see you soon", "later"}},
+		{`"unclosed phrase`, []string{"unclosed phrase"}},
+		{`"" !!!`, nil},
+		{"Grüße 2024", []string{"grüße", "2024"}},
+	}
+	for _, tt := range tests {
+		if got := searchTerms(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("searchTerms(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHighlightSnippet(t *testing.T) {
+	long := strings.Repeat("word ", 60)
+	tests := []struct {
+		name    string
+		content string
+		terms   []string
+		want    string
+	}{
+		{"no match", "nothing here", []string{"lunch"}, "nothing here"},
+		{"one match", "Lunch tomorrow?", []string{"lunch"}, "<mark>Lunch</mark> tomorrow?"},
+		{"whole words only", "lunchbox and lunch", []string{"lunch"}, "lunchbox and <mark>lunch</mark>"},
+		{"phrase", "see you soon then", []string{"you soon"}, "see <mark>you soon</mark> then"},
+		{"adjacent matches merge", "big cat", []string{"big", "cat"}, "<mark>big</mark> <mark>cat</mark>"},
+		{"escaped", "<b>lunch</b> & more", []string{"lunch"}, "&lt;b&gt;<mark>lunch</mark>&lt;/b&gt; &amp; more"},
+		{
+			"cut around a late match",
+			long + "lunch " + long,
+			[]string{"lunch"},
+			"…" + long[len(long)-snippetLead:] + "<mark>lunch</mark>" + (" " + long)[:snippetLength-snippetLead-len("lunch")] + "…",
+		},
+		{
+			"more before a match near the end",
+			long + "lunch",
+			[]string{"lunch"},
+			"…" + (long)[len(long)-(snippetLength-len("lunch")):] + "<mark>lunch</mark>",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.content, tt.terms); got != tt.want {
+				t.Errorf("highlightSnippet() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,316 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// reportMessageLimit is how many recent messages are attached to a report
+// that doesn't name any.
+const reportMessageLimit = 20
+
+// BlockUser stops another user from messaging the caller.
+func BlockUser(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	otherID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil || otherID == currentUser {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid user ID",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := blockUser(ctx, currentUser, otherID); err != nil {
+		log.Printf("[ERROR] Failed to block user %s: %v", otherID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to block user",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "User blocked",
+		Data:         nil,
+	})
+}
+
+// UnblockUser lifts a block the caller placed.
+func UnblockUser(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	otherID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid user ID",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err = utils.DB.Collection("blocks").DeleteOne(ctx, bson.M{
+		"blockerId": currentUser,
+		"blockedId": otherID,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to unblock user %s: %v", otherID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to unblock user",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "User unblocked",
+		Data:         nil,
+	})
+}
+
+// GetBlockedUsers lists the users the caller has blocked.
+func GetBlockedUsers(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	pipeline := []bson.M{
+		{"$match": bson.M{"blockerId": currentUser}},
+		{"$sort": bson.M{"createdAt": -1}},
+		{
+			"$lookup": bson.M{
+				"from":         "users",
+				"localField":   "blockedId",
+				"foreignField": "_id",
+				"as":           "user",
+			},
+		},
+		{"$unwind": "$user"},
+		{
+			"$project": bson.M{
+				"_id":       0,
+				"userId":    "$blockedId",
+				"username":  "$user.username",
+				"blockedAt": "$createdAt",
+			},
+		},
+	}
+
+	cursor, err := utils.DB.Collection("blocks").Aggregate(ctx, pipeline)
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch blocked users: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error fetching blocked users",
+			Data:         nil,
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	blocked := []gin.H{}
+	if err := cursor.All(ctx, &blocked); err != nil {
+		log.Printf("[ERROR] Failed to decode blocked users: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error processing blocked users",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Blocked users fetched successfully",
+		Data:         blocked,
+	})
+}
+
+// ReportUser files a report into the moderation queue with a snapshot of
+// the offending messages.
+func ReportUser(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	reportedID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil || reportedID == currentUser {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid user ID",
+			Data:         nil,
+		})
+		return
+	}
+
+	var req models.ReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request: reason is required",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Only messages the reported user sent to the reporter can be attached
+	filter := bson.M{"senderID": reportedID, "receiverID": currentUser}
+	opts := options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(reportMessageLimit)
+	if len(req.MessageIDs) > 0 {
+		ids := make([]primitive.ObjectID, 0, len(req.MessageIDs))
+		for _, hex := range req.MessageIDs {
+			id, err := primitive.ObjectIDFromHex(hex)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, models.Response{
+					ResponseCode: http.StatusBadRequest,
+					Message:      "Invalid message ID: " + hex,
+					Data:         nil,
+				})
+				return
+			}
+			ids = append(ids, id)
+		}
+		filter["_id"] = bson.M{"$in": ids}
+		opts.SetLimit(0)
+	}
+
+	cursor, err := utils.DB.Collection("messages").Find(ctx, filter, opts)
+	if err != nil {
+		log.Printf("[ERROR] Failed to snapshot reported messages: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to file report",
+			Data:         nil,
+		})
+		return
+	}
+	messages := []models.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		log.Printf("[ERROR] Failed to decode reported messages: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to file report",
+			Data:         nil,
+		})
+		return
+	}
+
+	report := models.Report{
+		ID:             primitive.NewObjectID(),
+		ReporterID:     currentUser,
+		ReportedUserID: reportedID,
+		Reason:         req.Reason,
+		Messages:       messages,
+		Status:         models.ReportStatusOpen,
+		CreatedAt:      time.Now(),
+	}
+	if _, err := utils.DB.Collection("reports").InsertOne(ctx, report); err != nil {
+		log.Printf("[ERROR] Failed to save report: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to file report",
+			Data:         nil,
+		})
+		return
+	}
+
+	if req.Block {
+		if err := blockUser(ctx, currentUser, reportedID); err != nil {
+			log.Printf("[ERROR] Failed to block reported user %s: %v", reportedID.Hex(), err)
+		}
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Report submitted",
+		Data: gin.H{
+			"reportId": report.ID.Hex(),
+		},
+	})
+}
+
+func blockUser(ctx context.Context, blockerID, blockedID primitive.ObjectID) error {
+	_, err := utils.DB.Collection("blocks").UpdateOne(ctx,
+		bson.M{"blockerId": blockerID, "blockedId": blockedID},
+		bson.M{"$setOnInsert": bson.M{"createdAt": time.Now()}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// blockBetween reports whether a has blocked b and whether b has blocked a.
+func blockBetween(ctx context.Context, a, b primitive.ObjectID) (aBlockedB, bBlockedA bool, err error) {
+	cursor, err := utils.DB.Collection("blocks").Find(ctx, bson.M{
+		"$or": []bson.M{
+			{"blockerId": a, "blockedId": b},
+			{"blockerId": b, "blockedId": a},
+		},
+	})
+	if err != nil {
+		return false, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []models.Block
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return false, false, err
+	}
+	for _, block := range blocks {
+		if block.BlockerID == a {
+			aBlockedB = true
+		} else {
+			bBlockedA = true
+		}
+	}
+	return aBlockedB, bBlockedA, nil
+}
+
+// blockedUserIDs returns everyone the user has blocked or been blocked by.
+func blockedUserIDs(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	cursor, err := utils.DB.Collection("blocks").Find(ctx, bson.M{
+		"$or": []bson.M{
+			{"blockerId": userID},
+			{"blockedId": userID},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var blocks []models.Block
+	if err := cursor.All(ctx, &blocks); err != nil {
+		return nil, err
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(blocks))
+	for _, block := range blocks {
+		if block.BlockerID == userID {
+			ids = append(ids, block.BlockedID)
+		} else {
+			ids = append(ids, block.BlockerID)
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/push"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// pushPreviewLength is the longest message preview shown in a
+// notification.
+const pushPreviewLength = 100
+
+// pushCollapseWindow is how long messages in a conversation are gathered
+// into a single notification.
+var pushCollapseWindow = 3 * time.Second
+
+// pushBurst collects the messages of one conversation that arrive within
+// the collapse window.
+type pushBurst struct {
+	count  int
+	latest models.Message
+}
+
+var (
+	pushMu     sync.Mutex
+	pushBursts = map[string]*pushBurst{}
+	// deliverBurst sends a burst once its window closes
+	deliverBurst = sendPush
+)
+
+// RegisterDevice stores a push token for the caller's device.
+func RegisterDevice(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.DeviceTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !push.ValidPlatform(req.Platform) {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request: token and a platform of android, ios or web are required",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	_, err := utils.DB.Collection("device_tokens").UpdateOne(ctx,
+		bson.M{"token": req.Token},
+		bson.M{
+			"$set": bson.M{
+				"userId":    userID,
+				"platform":  req.Platform,
+				"updatedAt": now,
+			},
+			"$setOnInsert": bson.M{"createdAt": now},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to register device for user %s: %v", userID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to register device",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Device registered successfully",
+		Data:         nil,
+	})
+}
+
+// UnregisterDevice removes one of the caller's push tokens, e.g. on logout.
+func UnregisterDevice(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := utils.DB.Collection("device_tokens").DeleteOne(ctx, bson.M{
+		"token":  c.Param("token"),
+		"userId": userID,
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to unregister device for user %s: %v", userID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to unregister device",
+			Data:         nil,
+		})
+		return
+	}
+	if result.DeletedCount == 0 {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "Device not found",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Device unregistered successfully",
+		Data:         nil,
+	})
+}
+
+// UpdateNotificationSettings changes whether the caller's notifications
+// include message previews.
+func UpdateNotificationSettings(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.NotificationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := utils.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"hideNotificationPreviews": req.HidePreviews}},
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update notification settings for user %s: %v", userID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to update notification settings",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Notification settings updated",
+		Data:         req,
+	})
+}
+
+// queuePush schedules a push notification for a new message. Messages in the
+// same conversation within the collapse window become one notification.
+func queuePush(message models.Message) {
+	key := utils.ConversationKey(message.SenderID, message.ReceiverID) + ":" + message.ReceiverID.Hex()
+
+	pushMu.Lock()
+	defer pushMu.Unlock()
+
+	if burst, ok := pushBursts[key]; ok {
+		burst.count++
+		burst.latest = message
+		return
+	}
+
+	pushBursts[key] = &pushBurst{count: 1, latest: message}
+	time.AfterFunc(pushCollapseWindow, func() {
+		pushMu.Lock()
+		burst := pushBursts[key]
+		delete(pushBursts, key)
+		pushMu.Unlock()
+
+		deliverBurst(burst)
+	})
+}
+
+// sendPush delivers a collapsed burst to the receiver's devices if they are
+// still not connected and haven't muted the conversation, and prunes tokens
+// the provider rejects.
+func sendPush(burst *pushBurst) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	message := burst.latest
+	presence, err := loadPresence(ctx, []primitive.ObjectID{message.ReceiverID})
+	if err != nil {
+		log.Printf("[ERROR] Failed to load presence for push: %v", err)
+		return
+	}
+	if presence[message.ReceiverID].Online {
+		return
+	}
+
+	settings, err := loadConversationSettings(ctx, message.ReceiverID, message.SenderID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load conversation settings for push: %v", err)
+		return
+	}
+	if settings.MutedUntil != nil && settings.MutedUntil.After(time.Now()) {
+		return
+	}
+
+	var receiver, sender models.User
+	users := utils.DB.Collection("users")
+	if err := users.FindOne(ctx, bson.M{"_id": message.ReceiverID}).Decode(&receiver); err != nil {
+		log.Printf("[ERROR] Failed to load push receiver %s: %v", message.ReceiverID.Hex(), err)
+		return
+	}
+	if err := users.FindOne(ctx, bson.M{"_id": message.SenderID}).Decode(&sender); err != nil {
+		log.Printf("[ERROR] Failed to load push sender %s: %v", message.SenderID.Hex(), err)
+		return
+	}
+
+	cursor, err := utils.DB.Collection("device_tokens").Find(ctx, bson.M{"userId": receiver.ID})
+	if err != nil {
+		log.Printf("[ERROR] Failed to load device tokens for user %s: %v", receiver.ID.Hex(), err)
+		return
+	}
+	var devices []models.DeviceToken
+	if err := cursor.All(ctx, &devices); err != nil {
+		log.Printf("[ERROR] Failed to decode device tokens for user %s: %v", receiver.ID.Hex(), err)
+		return
+	}
+
+	invalid := sendToDevices(ctx, devices, buildNotification(burst, sender, receiver))
+	if len(invalid) > 0 {
+		log.Printf("[INFO] Pruning %d invalid tokens for user %s", len(invalid), receiver.ID.Hex())
+		if _, err := utils.DB.Collection("device_tokens").DeleteMany(ctx, bson.M{"_id": bson.M{"$in": invalid}}); err != nil {
+			log.Printf("[ERROR] Failed to prune device tokens: %v", err)
+		}
+	}
+}
+
+// sendToDevices sends the notification to each device and returns the IDs
+// of those whose tokens the provider rejected.
+func sendToDevices(ctx context.Context, devices []models.DeviceToken, notification push.Notification) []primitive.ObjectID {
+	var invalid []primitive.ObjectID
+	for _, device := range devices {
+		err := push.Send(ctx, device.Platform, device.Token, notification)
+		switch {
+		case err == nil:
+		case errors.Is(err, push.ErrInvalidToken):
+			invalid = append(invalid, device.ID)
+		case errors.Is(err, push.ErrNoProvider):
+			// Push is not configured for this platform
+		default:
+			log.Printf("[ERROR] Failed to send push to user %s: %v", device.UserID.Hex(), err)
+		}
+	}
+	return invalid
+}
+
+// buildNotification renders a burst of messages as a notification, leaving
+// out the content when the receiver has hidden previews.
+func buildNotification(burst *pushBurst, sender, receiver models.User) push.Notification {
+	message := burst.latest
+
+	body := "New message"
+	if burst.count > 1 {
+		body = fmt.Sprintf("%d new messages", burst.count)
+	}
+	if !receiver.HideNotificationPreviews && message.Content != "" {
+		body = truncate(message.Content, pushPreviewLength)
+		if burst.count > 1 {
+			body = fmt.Sprintf("%s (+%d more)", body, burst.count-1)
+		}
+	}
+
+	return push.Notification{
+		Title:       sender.Username,
+		Body:        body,
+		CollapseKey: utils.ConversationKey(message.SenderID, message.ReceiverID),
+		Data: map[string]string{
+			"type":      "new-message",
+			"messageId": message.ID.Hex(),
+			"senderId":  message.SenderID.Hex(),
+			"count":     fmt.Sprint(burst.count),
+		},
+	}
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
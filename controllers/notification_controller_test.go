@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/push"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestBuildNotification(t *testing.T) {
+	sender := models.User{ID: primitive.NewObjectID(), Username: "sam"}
+	message := models.Message{
+		ID:         primitive.NewObjectID(),
+		SenderID:   sender.ID,
+		ReceiverID: primitive.NewObjectID(),
+		Content:    "see you at noon",
+	}
+	long := message
+	long.Content = strings.Repeat("a", pushPreviewLength+20)
+	attachmentOnly := message
+	attachmentOnly.Content = ""
+
+	tests := []struct {
+		name    string
+		burst   pushBurst
+		hide    bool
+		want    string
+		wantLen int
+	}{
+		{"single preview", pushBurst{count: 1, latest: message}, false, "see you at noon", 0},
+		{"burst preview", pushBurst{count: 3, latest: message}, false, "see you at noon (+2 more)", 0},
+		{"single hidden", pushBurst{count: 1, latest: message}, true, "New message", 0},
+		{"burst hidden", pushBurst{count: 4, latest: message}, true, "4 new messages", 0},
+		{"attachment only", pushBurst{count: 1, latest: attachmentOnly}, false, "New message", 0},
+		{"attachment only burst", pushBurst{count: 2, latest: attachmentOnly}, false, "2 new messages", 0},
+		{"long preview", pushBurst{count: 1, latest: long}, false, strings.Repeat("a", pushPreviewLength-1) + "…", pushPreviewLength},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			burst := tt.burst
+			n := buildNotification(&burst, sender, models.User{ID: message.ReceiverID, HideNotificationPreviews: tt.hide})
+			if n.Body != tt.want {
+				t.Errorf("Body = %q, want %q", n.Body, tt.want)
+			}
+			if tt.wantLen > 0 && len([]rune(n.Body)) != tt.wantLen {
+				t.Errorf("Body has %d runes, want %d", len([]rune(n.Body)), tt.wantLen)
+			}
+			if n.Title != "sam" || n.Data["messageId"] != message.ID.Hex() || n.Data["count"] == "" {
+				t.Errorf("notification = %+v", n)
+			}
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want string
+	}{
+		{"", 5, ""},
+		{"hello", 5, "hello"},
+		{"hello!", 5, "hell…"},
+		{"héllo wörld", 6, "héllo…"},
+	}
+	for _, tt := range tests {
+		if got := truncate(tt.s, tt.n); got != tt.want {
+			t.Errorf("truncate(%q, %d) = %q, want %q", tt.s, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestQueuePushCollapses(t *testing.T) {
+	window, deliver := pushCollapseWindow, deliverBurst
+	t.Cleanup(func() { pushCollapseWindow, deliverBurst = window, deliver })
+
+	bursts := make(chan *pushBurst, 4)
+	pushCollapseWindow = 50 * time.Millisecond
+	deliverBurst = func(burst *pushBurst) { bursts <- burst }
+
+	alice, bob, carol := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	queuePush(models.Message{SenderID: alice, ReceiverID: bob, Content: "one"})
+	queuePush(models.Message{SenderID: alice, ReceiverID: bob, Content: "two"})
+	queuePush(models.Message{SenderID: carol, ReceiverID: bob, Content: "other"})
+	queuePush(models.Message{SenderID: alice, ReceiverID: bob, Content: "three"})
+
+	got := map[string]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case burst := <-bursts:
+			got[burst.latest.Content] = burst.count
+		case <-time.After(time.Second):
+			t.Fatalf("got %d bursts, want 2", i)
+		}
+	}
+	if want := map[string]int{"three": 3, "other": 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("bursts = %v, want %v", got, want)
+	}
+}
+
+func TestSendToDevicesReportsInvalidTokens(t *testing.T) {
+	fake := push.NewFake()
+	fake.MarkInvalid("stale")
+	push.SetProvider(push.PlatformAndroid, fake)
+
+	valid, stale, ios := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	devices := []models.DeviceToken{
+		{ID: valid, Token: "device", Platform: push.PlatformAndroid},
+		{ID: stale, Token: "stale", Platform: push.PlatformAndroid},
+		{ID: ios, Token: "phone", Platform: push.PlatformIOS},
+	}
+	invalid := sendToDevices(context.Background(), devices, push.Notification{Title: "sam", Body: "hi"})
+	if !reflect.DeepEqual(invalid, []primitive.ObjectID{stale}) {
+		t.Errorf("invalid = %v, want [%s]", invalid, stale.Hex())
+	}
+	if sent := fake.Sent(); len(sent) != 1 || sent[0].Token != "device" {
+		t.Errorf("sent = %+v, want only the valid device", sent)
+	}
+}
@@ -0,0 +1,366 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PusherWebhook receives channel and presence events from Pusher and keeps
+// the presence collection up to date.
+func PusherWebhook(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+		return
+	}
+
+	// Verifies the X-Pusher-Key and X-Pusher-Signature headers
+	webhook, err := utils.PusherClient.Webhook(c.Request.Header, body)
+	if err != nil {
+		log.Printf("[WARN] Rejected Pusher webhook: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid webhook signature"})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, event := range webhook.Events {
+		var err error
+		switch event.Name {
+		case "channel_occupied":
+			if userID, ok := channelOwner(event.Channel); ok {
+				err = addPresenceChannel(ctx, userID, event.Channel)
+			}
+		case "channel_vacated":
+			if userID, ok := channelOwner(event.Channel); ok {
+				err = removePresenceChannel(ctx, userID, event.Channel)
+			}
+		case "member_added":
+			if userID, convErr := primitive.ObjectIDFromHex(event.UserID); convErr == nil {
+				err = addPresenceChannel(ctx, userID, event.Channel)
+			}
+		case "member_removed":
+			if userID, convErr := primitive.ObjectIDFromHex(event.UserID); convErr == nil {
+				err = removePresenceChannel(ctx, userID, event.Channel)
+			}
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to handle Pusher %s event on %s: %v", event.Name, event.Channel, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// channelOwner returns the user a personal chat channel belongs to.
+func channelOwner(channel string) (primitive.ObjectID, bool) {
+	for _, prefix := range []string{"private-chat-", "presence-chat-"} {
+		if id, ok := strings.CutPrefix(channel, prefix); ok {
+			userID, err := primitive.ObjectIDFromHex(id)
+			return userID, err == nil
+		}
+	}
+	return primitive.NilObjectID, false
+}
+
+// heartbeatTTL is how long a polling client stays online after its last
+// heartbeat.
+const heartbeatTTL = 60 * time.Second
+
+// PresenceHeartbeat keeps clients without a realtime connection online.
+func PresenceHeartbeat(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := markOnline(ctx, userID, bson.M{"heartbeatAt": time.Now()}); err != nil {
+		log.Printf("[ERROR] Failed to record heartbeat for user %s: %v", userID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to record heartbeat",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Heartbeat recorded",
+		Data: gin.H{
+			"expiresIn": int(heartbeatTTL.Seconds()),
+		},
+	})
+}
+
+// UpdatePresenceSettings changes the caller's last-seen privacy setting.
+func UpdatePresenceSettings(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.PresenceSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := utils.DB.Collection("users").UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": bson.M{"hideLastSeen": req.HideLastSeen}},
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to update presence settings for user %s: %v", userID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to update presence settings",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Presence settings updated",
+		Data:         req,
+	})
+}
+
+// StartPresenceSweeper periodically takes users offline whose only source of
+// presence was a heartbeat that has since expired.
+func StartPresenceSweeper() {
+	go func() {
+		ticker := time.NewTicker(heartbeatTTL / 2)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			sweepPresence()
+		}
+	}()
+}
+
+func sweepPresence() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	cursor, err := utils.DB.Collection("presence").Find(ctx, stalePresenceFilter())
+	if err != nil {
+		log.Printf("[ERROR] Failed to find stale presence: %v", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	var stale []models.Presence
+	if err := cursor.All(ctx, &stale); err != nil {
+		log.Printf("[ERROR] Failed to decode stale presence: %v", err)
+		return
+	}
+	for _, p := range stale {
+		if err := markOffline(ctx, p.UserID); err != nil {
+			log.Printf("[ERROR] Failed to mark user %s offline: %v", p.UserID.Hex(), err)
+		}
+	}
+}
+
+// stalePresenceFilter matches online users with no open channels and no
+// recent heartbeat.
+func stalePresenceFilter() bson.M {
+	return bson.M{
+		"online":      true,
+		"channels":    bson.M{"$size": 0},
+		"heartbeatAt": bson.M{"$not": bson.M{"$gte": time.Now().Add(-heartbeatTTL)}},
+	}
+}
+
+func addPresenceChannel(ctx context.Context, userID primitive.ObjectID, channel string) error {
+	_, err := utils.DB.Collection("presence").UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$addToSet": bson.M{"channels": channel}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return err
+	}
+	return markOnline(ctx, userID, nil)
+}
+
+func removePresenceChannel(ctx context.Context, userID primitive.ObjectID, channel string) error {
+	_, err := utils.DB.Collection("presence").UpdateOne(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$pull": bson.M{"channels": channel}},
+	)
+	if err != nil {
+		return err
+	}
+	return markOffline(ctx, userID)
+}
+
+// markOnline sets the user online along with any extra fields, and tells
+// their contacts if they were offline before.
+func markOnline(ctx context.Context, userID primitive.ObjectID, set bson.M) error {
+	fields := bson.M{"online": true, "lastSeenAt": time.Now()}
+	for k, v := range set {
+		fields[k] = v
+	}
+
+	var before models.Presence
+	err := utils.DB.Collection("presence").FindOneAndUpdate(ctx,
+		bson.M{"_id": userID},
+		bson.M{
+			"$set":         fields,
+			"$setOnInsert": bson.M{"channels": []string{}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.Before),
+	).Decode(&before)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	if !before.Online {
+		broadcastPresence(ctx, userID, true, fields["lastSeenAt"].(time.Time))
+	}
+	return nil
+}
+
+// markOffline takes the user offline once neither an open channel nor a
+// recent heartbeat keeps them online.
+func markOffline(ctx context.Context, userID primitive.ObjectID) error {
+	now := time.Now()
+	filter := stalePresenceFilter()
+	filter["_id"] = userID
+
+	result, err := utils.DB.Collection("presence").UpdateOne(ctx,
+		filter,
+		bson.M{"$set": bson.M{"online": false, "lastSeenAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.ModifiedCount > 0 {
+		broadcastPresence(ctx, userID, false, now)
+	}
+	return nil
+}
+
+// broadcastPresence sends a presence-changed event to everyone the user has
+// a conversation with. Failures are logged; presence is already stored.
+func broadcastPresence(ctx context.Context, userID primitive.ObjectID, online bool, lastSeenAt time.Time) {
+	var user models.User
+	if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		log.Printf("[ERROR] Failed to load user %s for presence broadcast: %v", userID.Hex(), err)
+		return
+	}
+
+	partners, err := conversationPartners(ctx, userID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load contacts of user %s: %v", userID.Hex(), err)
+		return
+	}
+	if len(partners) == 0 {
+		return
+	}
+
+	eventData := gin.H{
+		"userId":     userID.Hex(),
+		"online":     online,
+		"lastSeenAt": lastSeenFor(user, models.Presence{LastSeenAt: lastSeenAt}),
+	}
+
+	recipients := make([]string, 0, len(partners))
+	for _, id := range partners {
+		recipients = append(recipients, id.Hex())
+	}
+	if err := utils.TriggerUsers(recipients, "presence-changed", eventData); err != nil {
+		log.Printf("[ERROR] Failed to trigger presence-changed for user %s: %v", userID.Hex(), err)
+	}
+}
+
+// conversationPartners returns every user that has exchanged a message with
+// the given user, leaving out anyone either of them has blocked.
+func conversationPartners(ctx context.Context, userID primitive.ObjectID) ([]primitive.ObjectID, error) {
+	messages := utils.DB.Collection("messages")
+
+	blocked, err := blockedUserIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	received, err := messages.Distinct(ctx, "senderID", bson.M{"receiverID": userID})
+	if err != nil {
+		return nil, err
+	}
+	sent, err := messages.Distinct(ctx, "receiverID", bson.M{"senderID": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[primitive.ObjectID]bool)
+	for _, id := range blocked {
+		seen[id] = true
+	}
+	partners := []primitive.ObjectID{}
+	for _, v := range append(received, sent...) {
+		id, ok := v.(primitive.ObjectID)
+		if !ok || seen[id] {
+			continue
+		}
+		seen[id] = true
+		partners = append(partners, id)
+	}
+	return partners, nil
+}
+
+// lastSeenFor returns the last-seen time other users may see, or nil if the
+// user keeps it private or has never been seen.
+func lastSeenFor(user models.User, p models.Presence) *time.Time {
+	if user.HideLastSeen || p.LastSeenAt.IsZero() {
+		return nil
+	}
+	return &p.LastSeenAt
+}
+
+// loadPresence returns the presence records for the given users keyed by ID.
+// Users with no record are missing from the map and should be treated as
+// offline.
+func loadPresence(ctx context.Context, userIDs []primitive.ObjectID) (map[primitive.ObjectID]models.Presence, error) {
+	presence := make(map[primitive.ObjectID]models.Presence, len(userIDs))
+	if len(userIDs) == 0 {
+		return presence, nil
+	}
+
+	cursor, err := utils.DB.Collection("presence").Find(ctx, bson.M{"_id": bson.M{"$in": userIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var records []models.Presence
+	if err := cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	for _, p := range records {
+		presence[p.UserID] = p
+	}
+	return presence, nil
+}
@@ -0,0 +1,269 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Profile field limits
+const (
+	maxDisplayNameLength = 64
+	maxBioLength         = 500
+	maxStatusTextLength  = 140
+)
+
+// GetMe returns the caller's own profile and settings.
+func GetMe(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var user models.User
+	if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "User not found",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Profile fetched successfully",
+		Data:         ownProfile(user),
+	})
+}
+
+// UpdateMe changes the caller's profile and tells their contacts.
+func UpdateMe(c *gin.Context) {
+	userID := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{}
+	if req.Username != nil {
+		username := strings.TrimSpace(*req.Username)
+		if username == "" {
+			profileBadRequest(c, "Username cannot be empty")
+			return
+		}
+		set["username"] = username
+	}
+	if req.DisplayName != nil {
+		if len([]rune(*req.DisplayName)) > maxDisplayNameLength {
+			profileBadRequest(c, "Display name is too long")
+			return
+		}
+		set["displayName"] = strings.TrimSpace(*req.DisplayName)
+	}
+	if req.Bio != nil {
+		if len([]rune(*req.Bio)) > maxBioLength {
+			profileBadRequest(c, "Bio is too long")
+			return
+		}
+		set["bio"] = *req.Bio
+	}
+	if req.StatusText != nil {
+		if len([]rune(*req.StatusText)) > maxStatusTextLength {
+			profileBadRequest(c, "Status text is too long")
+			return
+		}
+		set["statusText"] = *req.StatusText
+	}
+	if req.TimeZone != nil {
+		if _, err := time.LoadLocation(*req.TimeZone); err != nil || *req.TimeZone == "Local" {
+			profileBadRequest(c, "Unknown time zone")
+			return
+		}
+		set["timeZone"] = *req.TimeZone
+	}
+	if req.AvatarAttachmentID != nil {
+		if *req.AvatarAttachmentID == "" {
+			set["avatarUrl"] = ""
+		} else {
+			attachmentID, err := primitive.ObjectIDFromHex(*req.AvatarAttachmentID)
+			if err != nil {
+				profileBadRequest(c, "Invalid avatar attachment ID")
+				return
+			}
+			var attachment models.Attachment
+			err = utils.DB.Collection("attachments").FindOne(ctx, bson.M{
+				"_id":     attachmentID,
+				"ownerId": userID,
+			}).Decode(&attachment)
+			if err != nil {
+				profileBadRequest(c, "Avatar attachment not found")
+				return
+			}
+			if !strings.HasPrefix(attachment.ContentType, "image/") {
+				profileBadRequest(c, "Avatar must be an image")
+				return
+			}
+			set["avatarUrl"] = attachment.URL
+		}
+	}
+
+	if len(set) == 0 {
+		profileBadRequest(c, "Nothing to update")
+		return
+	}
+
+	var user models.User
+	err := utils.DB.Collection("users").FindOneAndUpdate(ctx,
+		bson.M{"_id": userID},
+		bson.M{"$set": set},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&user)
+	if mongo.IsDuplicateKeyError(err) {
+		c.JSON(http.StatusConflict, models.Response{
+			ResponseCode: http.StatusConflict,
+			Message:      "Username already taken",
+			Data:         nil,
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("[ERROR] Failed to update profile of user %s: %v", userID.Hex(), err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to update profile",
+			Data:         nil,
+		})
+		return
+	}
+
+	broadcastProfile(ctx, user)
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Profile updated successfully",
+		Data:         ownProfile(user),
+	})
+}
+
+// GetUserProfile returns another user's public profile.
+func GetUserProfile(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	userID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		profileBadRequest(c, "Invalid user ID")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	notFound := func() {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "User not found",
+			Data:         nil,
+		})
+	}
+
+	// Blocked users can't see each other
+	blockedByMe, blockedMe, err := blockBetween(ctx, currentUser, userID)
+	if err != nil || blockedByMe || blockedMe {
+		notFound()
+		return
+	}
+
+	var user models.User
+	if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": userID}).Decode(&user); err != nil {
+		notFound()
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Profile fetched successfully",
+		Data:         publicProfile(user),
+	})
+}
+
+func profileBadRequest(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, models.Response{
+		ResponseCode: http.StatusBadRequest,
+		Message:      message,
+		Data:         nil,
+	})
+}
+
+func publicProfile(user models.User) models.PublicProfile {
+	return models.PublicProfile{
+		ID:          user.ID,
+		Username:    user.Username,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
+		StatusText:  user.StatusText,
+		TimeZone:    user.TimeZone,
+		IsBot:       user.IsBot,
+	}
+}
+
+// displayName is how the user is shown by name, falling back to the
+// username when no display name is set.
+func displayName(user models.User) string {
+	if user.DisplayName != "" {
+		return user.DisplayName
+	}
+	return user.Username
+}
+
+// ownProfile is the public profile plus the settings only the user sees.
+func ownProfile(user models.User) gin.H {
+	return gin.H{
+		"profile":                  publicProfile(user),
+		"email":                    user.Email,
+		"hideLastSeen":             user.HideLastSeen,
+		"hideNotificationPreviews": user.HideNotificationPreviews,
+		"emailDigestsDisabled":     user.EmailDigestsDisabled,
+		"messagePermission":        user.MessagePermission,
+	}
+}
+
+// broadcastProfile sends the new public profile to the user's contacts.
+// Failures are logged; the profile is already saved.
+func broadcastProfile(ctx context.Context, user models.User) {
+	contacts, err := contactIDs(ctx, user.ID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load contacts of user %s: %v", user.ID.Hex(), err)
+		return
+	}
+	if len(contacts) == 0 {
+		return
+	}
+
+	recipients := make([]string, 0, len(contacts))
+	for _, id := range contacts {
+		recipients = append(recipients, id.Hex())
+	}
+	if err := utils.TriggerUsers(recipients, "profile-updated", publicProfile(user)); err != nil {
+		log.Printf("[ERROR] Failed to trigger profile-updated for user %s: %v", user.ID.Hex(), err)
+	}
+}
@@ -1,41 +1,125 @@
 package controllers
 
 import (
-	"encoding/json"
+	"context"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/pusher/pusher-http-go/v5"
+	"github.com/sajanIocod/chat_backend/models"
 	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 func PusherAuth(c *gin.Context) {
 	socketID := c.PostForm("socket_id")
 	channel := c.PostForm("channel_name")
 
-	// Get user ID from JWT token (assuming you have middleware setting this)
+	// Get user ID from JWT token
 	userID := c.GetString("userID")
-	// Create request payload
-	payload := map[string]interface{}{
-		"socket_id":    socketID,
-		"channel_name": channel,
-		"user_id":      userID,
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
 	}
 
-	// Marshal to []byte
-	payloadBytes, err := json.Marshal(payload)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	allowed, err := canSubscribe(ctx, userID, channel)
 	if err != nil {
-		c.JSON(500, gin.H{
-			"error": "Failed to marshal payload: " + err.Error(),
-		})
+		log.Printf("[ERROR] Failed to check subscription of user %s to %s: %v", userID, channel, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to authorise channel"})
+		return
+	}
+	if !allowed {
+		log.Printf("[WARN] User %s denied subscription to channel %s", userID, channel)
+		c.JSON(http.StatusForbidden, gin.H{"error": "Not allowed to subscribe to this channel"})
 		return
 	}
 
-	response, err := utils.PusherClient.AuthorizePrivateChannel(payloadBytes)
+	// The Pusher library expects the form-encoded body it documents
+	params := url.Values{}
+	params.Set("socket_id", socketID)
+	params.Set("channel_name", channel)
+	payloadBytes := []byte(params.Encode())
+
+	var response []byte
+	if strings.HasPrefix(channel, "presence-") {
+		var user models.User
+		if err := utils.DB.Collection("users").FindOne(ctx, bson.M{"_id": utils.ObjectIDFromHex(userID)}).Decode(&user); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "User not found"})
+			return
+		}
+
+		response, err = utils.PusherClient.AuthorizePresenceChannel(payloadBytes, pusher.MemberData{
+			UserID: userID,
+			UserInfo: map[string]string{
+				"username": user.Username,
+			},
+		})
+	} else {
+		response, err = utils.PusherClient.AuthorizePrivateChannel(payloadBytes)
+	}
 	if err != nil {
-		c.JSON(403, gin.H{
+		c.JSON(http.StatusForbidden, gin.H{
 			"error": "Failed to authenticate Pusher channel: " + err.Error(),
 		})
 		return
 	}
 
-	c.JSON(200, response)
+	c.Data(http.StatusOK, "application/json", response)
+}
+
+// canSubscribe reports whether userID may join the given channel. Users may
+// only join their own chat channel, or the channel of a conversation they are
+// a member of and where neither member has blocked the other.
+func canSubscribe(ctx context.Context, userID, channel string) (bool, error) {
+	peer, ok := channelPeer(userID, channel)
+	if !ok || peer == "" {
+		return ok, nil
+	}
+	peerID, err := primitive.ObjectIDFromHex(peer)
+	if err != nil {
+		return false, nil
+	}
+	blockedByMe, blockedMe, err := blockBetween(ctx, utils.ObjectIDFromHex(userID), peerID)
+	if err != nil {
+		return false, err
+	}
+	return !blockedByMe && !blockedMe, nil
+}
+
+// channelPeer checks that a channel name is one userID may join as far as
+// the name goes. For a conversation channel it also returns the other
+// member, empty for the user's own channel.
+func channelPeer(userID, channel string) (string, bool) {
+	for _, prefix := range []string{"private-", "presence-"} {
+		if !strings.HasPrefix(channel, prefix) {
+			continue
+		}
+		name := strings.TrimPrefix(channel, prefix)
+
+		if id, ok := strings.CutPrefix(name, "chat-"); ok {
+			return "", id == userID
+		}
+		if key, ok := strings.CutPrefix(name, "conversation-"); ok {
+			members := strings.Split(key, "-")
+			if len(members) != 2 || members[0] == members[1] {
+				return "", false
+			}
+			switch userID {
+			case members[0]:
+				return members[1], true
+			case members[1]:
+				return members[0], true
+			}
+			return "", false
+		}
+	}
+	return "", false
 }
@@ -0,0 +1,31 @@
+package controllers
+
+import "testing"
+
+func TestChannelPeer(t *testing.T) {
+	const me, peer = "aaaaaaaaaaaaaaaaaaaaaaaa", "bbbbbbbbbbbbbbbbbbbbbbbb"
+	tests := []struct {
+		channel string
+		peer    string
+		ok      bool
+	}{
+		{"private-chat-" + me, "", true},
+		{"presence-chat-" + me, "", true},
+		{"private-chat-" + peer, "", false},
+		{"chat-" + me, "", false},
+		{"presence-conversation-" + me + "-" + peer, peer, true},
+		{"presence-conversation-" + peer + "-" + me, peer, true},
+		{"private-conversation-" + me + "-" + peer, peer, true},
+		{"presence-conversation-" + me + "-" + me, "", false},
+		{"presence-conversation-" + peer + "-cccccccccccccccccccccccc", "", false},
+		{"presence-conversation-" + me, "", false},
+		{"presence-conversation-" + me + "-" + peer + "-x", "", false},
+		{"public-" + me, "", false},
+	}
+	for _, tt := range tests {
+		got, ok := channelPeer(me, tt.channel)
+		if got != tt.peer || ok != tt.ok {
+			t.Errorf("channelPeer(%q) = %q, %v, want %q, %v", tt.channel, got, ok, tt.peer, tt.ok)
+		}
+	}
+}
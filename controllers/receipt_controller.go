@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AckMessages is called by the receiving client once messages have arrived
+// on the device, moving them from sent to delivered.
+func AckMessages(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	var req models.AckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid request",
+			Data:         nil,
+		})
+		return
+	}
+
+	messageIDs := make([]primitive.ObjectID, 0, len(req.MessageIDs))
+	for _, hex := range req.MessageIDs {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{
+				ResponseCode: http.StatusBadRequest,
+				Message:      "Invalid message ID: " + hex,
+				Data:         nil,
+			})
+			return
+		}
+		messageIDs = append(messageIDs, id)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Only messages addressed to the caller that haven't got further yet.
+	// Older messages have no status, so seen is checked too
+	filter := bson.M{
+		"_id":        bson.M{"$in": messageIDs},
+		"receiverID": currentUser,
+		"seen":       false,
+		"status":     bson.M{"$nin": []string{models.MessageStatusDelivered, models.MessageStatusRead}},
+	}
+
+	messages, err := findMessages(ctx, filter)
+	if err != nil {
+		log.Printf("[ERROR] Failed to find messages to acknowledge: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to acknowledge messages",
+			Data:         nil,
+		})
+		return
+	}
+
+	now := time.Now()
+	result, err := utils.DB.Collection("messages").UpdateMany(ctx, filter, bson.M{
+		"$set": bson.M{
+			"status":      models.MessageStatusDelivered,
+			"deliveredAt": now,
+		},
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to acknowledge messages: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to acknowledge messages",
+			Data:         nil,
+		})
+		return
+	}
+
+	sendReceipts(messages, models.MessageStatusDelivered, now)
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Messages acknowledged",
+		Data: gin.H{
+			"modifiedCount": result.ModifiedCount,
+		},
+	})
+}
+
+// GetReadPointers returns how far each member of a conversation has read.
+// The conversation is identified by the peer's user ID.
+func GetReadPointers(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	peerID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid conversation ID",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := utils.DB.Collection("read_pointers").Find(ctx, bson.M{
+		"conversationKey": utils.ConversationKey(currentUser, peerID),
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch read pointers: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to fetch read pointers",
+			Data:         nil,
+		})
+		return
+	}
+	defer cursor.Close(ctx)
+
+	pointers := []models.ReadPointer{}
+	if err := cursor.All(ctx, &pointers); err != nil {
+		log.Printf("[ERROR] Failed to decode read pointers: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to process read pointers",
+			Data:         nil,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Read pointers fetched successfully",
+		Data:         pointers,
+	})
+}
+
+func findMessages(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.Message, error) {
+	opts = append([]*options.FindOptions{options.Find().SetSort(bson.M{"createdAt": 1})}, opts...)
+	cursor, err := utils.DB.Collection("messages").Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []models.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// advanceReadPointer moves the reader's pointer in a conversation to the
+// given message.
+func advanceReadPointer(ctx context.Context, readerID, peerID, messageID primitive.ObjectID, at time.Time) error {
+	key := utils.ConversationKey(readerID, peerID)
+	_, err := utils.DB.Collection("read_pointers").UpdateOne(ctx,
+		bson.M{"conversationKey": key, "userId": readerID},
+		bson.M{"$set": bson.M{
+			"lastReadMessageId": messageID,
+			"lastReadAt":        at,
+		}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// sendReceipts notifies the senders of the given messages that they reached
+// a new status. Failures are logged; the status is already stored.
+func sendReceipts(messages []models.Message, status string, at time.Time) {
+	bySender := map[primitive.ObjectID][]string{}
+	for _, m := range messages {
+		bySender[m.SenderID] = append(bySender[m.SenderID], m.ID.Hex())
+	}
+
+	for senderID, ids := range bySender {
+		eventData := gin.H{
+			"messageIds": ids,
+			"status":     status,
+			"at":         at,
+		}
+		err := utils.PusherClient.Trigger(utils.UserChannel(senderID.Hex()), "receipt", eventData)
+		if err != nil {
+			log.Printf("[ERROR] Failed to trigger receipt event: %v", err)
+		}
+	}
+}
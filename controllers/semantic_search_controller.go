@@ -0,0 +1,336 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/llm"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"github.com/sajanIocod/chat_backend/vectors"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// embeddingBatchSize is how many messages are embedded in one call.
+	embeddingBatchSize = 64
+	// semanticSearchMaxQuery is the longest query accepted, in bytes.
+	semanticSearchMaxQuery = 1000
+)
+
+// embeddingNudge wakes the embedding job when a message is sent. It holds
+// at most one wake-up; the job embeds everything pending each time.
+var embeddingNudge = make(chan struct{}, 1)
+
+// StartEmbeddingJob embeds messages for semantic search in the background:
+// new ones shortly after they are sent, and any that were missed (including
+// those sent before search was enabled) every EMBEDDING_INTERVAL (default
+// 10m).
+func StartEmbeddingJob() {
+	if !llm.Configured(llm.FeatureEmbeddings) {
+		log.Printf("[WARN] Semantic search disabled: no embeddings provider")
+		return
+	}
+	vectors.Init()
+	interval := envDuration("EMBEDDING_INTERVAL", 10*time.Minute)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			embedPendingMessages()
+			select {
+			case <-embeddingNudge:
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// queueEmbedding tells the embedding job there is a new message.
+func queueEmbedding() {
+	select {
+	case embeddingNudge <- struct{}{}:
+	default:
+	}
+}
+
+// embedPendingMessages embeds messages without embeddedAt in batches until
+// none are left. A batch that fails is retried on the next run.
+func embedPendingMessages() {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		n, err := embedMessageBatch(ctx)
+		cancel()
+		if errors.Is(err, llm.ErrNoEmbeddings) {
+			log.Printf("[ERROR] The embeddings provider can't embed text")
+			return
+		}
+		if err != nil {
+			log.Printf("[ERROR] Failed to embed messages: %v", err)
+			return
+		}
+		if n < embeddingBatchSize {
+			return
+		}
+	}
+}
+
+func embedMessageBatch(ctx context.Context) (int, error) {
+	messages, err := findMessages(ctx, bson.M{"embeddedAt": nil},
+		options.Find().SetSort(bson.M{"_id": 1}).SetLimit(embeddingBatchSize),
+	)
+	if err != nil || len(messages) == 0 {
+		return 0, err
+	}
+
+	var texts []string
+	var embedded []models.Message
+	var ids []primitive.ObjectID
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+		if content := strings.TrimSpace(message.Content); content != "" {
+			texts = append(texts, content)
+			embedded = append(embedded, message)
+		}
+	}
+
+	if len(texts) > 0 {
+		// Messages are embedded for everyone, so no user's quota is used
+		result, err := llm.Embed(llm.WithUser(ctx, llm.SystemUser), llm.FeatureEmbeddings, llm.EmbedRequest{Texts: texts})
+		if err != nil {
+			return 0, err
+		}
+		now := time.Now()
+		entries := make([]vectors.Entry, 0, len(embedded))
+		for i, message := range embedded {
+			entries = append(entries, vectors.Entry{
+				MessageID:    message.ID,
+				Participants: []primitive.ObjectID{message.SenderID, message.ReceiverID},
+				Model:        result.Model,
+				Vector:       result.Vectors[i],
+				CreatedAt:    now,
+			})
+		}
+		if err := vectors.Store(ctx, entries); err != nil {
+			return 0, err
+		}
+	}
+
+	// Messages with nothing to embed are marked too, so they aren't
+	// picked up again
+	_, err = utils.DB.Collection("messages").UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$set": bson.M{"embeddedAt": time.Now()}},
+	)
+	return len(messages), err
+}
+
+// SemanticSearch finds the caller's messages closest in meaning to q, in
+// any of their conversations. Each result comes with the context messages
+// either side of it (?context=, default 2, at most 10). Messages are
+// searchable a little while after they are sent.
+func SemanticSearch(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" || len(query) > semanticSearchMaxQuery {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "q is required and must be at most 1000 characters",
+			Data:         nil,
+		})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit < 1 || limit > 50 {
+		limit = 10
+	}
+	around, _ := strconv.Atoi(c.DefaultQuery("context", "2"))
+	if around < 0 || around > 10 {
+		around = 2
+	}
+
+	if !llm.Configured(llm.FeatureEmbeddings) {
+		c.JSON(http.StatusServiceUnavailable, models.Response{
+			ResponseCode: http.StatusServiceUnavailable,
+			Message:      "Semantic search is not available",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Second)
+	defer cancel()
+
+	embedding, err := llm.Embed(llm.WithUser(ctx, currentUser.Hex()), llm.FeatureEmbeddings, llm.EmbedRequest{
+		Texts: []string{query},
+		Query: true,
+	})
+	if aiQuotaExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		semanticSearchError(c, err)
+		return
+	}
+	matches, err := vectors.Search(ctx, embedding.Vectors[0], embedding.Model, currentUser, limit)
+	if err != nil {
+		semanticSearchError(c, err)
+		return
+	}
+
+	ids := make([]primitive.ObjectID, 0, len(matches))
+	for _, match := range matches {
+		ids = append(ids, match.MessageID)
+	}
+	// The index may be behind on deletions, so access is checked against
+	// the messages themselves
+	messages, err := findMessages(ctx, bson.M{
+		"_id": bson.M{"$in": ids},
+		"$or": []bson.M{{"senderID": currentUser}, {"receiverID": currentUser}},
+	})
+	if err != nil {
+		semanticSearchError(c, err)
+		return
+	}
+	byID := make(map[primitive.ObjectID]models.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+
+	found := make([]models.Message, 0, len(matches))
+	scores := make([]float64, 0, len(matches))
+	for _, match := range matches {
+		if message, ok := byID[match.MessageID]; ok {
+			found = append(found, message)
+			scores = append(scores, match.Score)
+		}
+	}
+	results, err := searchResults(ctx, currentUser, found, around)
+	if err != nil {
+		semanticSearchError(c, err)
+		return
+	}
+	for i := range results {
+		results[i].Score = scores[i]
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Search completed successfully",
+		Data: gin.H{
+			"query":   query,
+			"results": results,
+		},
+	})
+}
+
+func semanticSearchError(c *gin.Context, err error) {
+	log.Printf("[ERROR] Semantic search failed: %v", err)
+	c.JSON(http.StatusInternalServerError, models.Response{
+		ResponseCode: http.StatusInternalServerError,
+		Message:      "Failed to search messages",
+		Data:         nil,
+	})
+}
+
+// searchResults wraps the user's messages with their conversation's peer
+// and, when around is positive, that many messages either side of each.
+func searchResults(ctx context.Context, userID primitive.ObjectID, messages []models.Message, around int) ([]models.SearchResult, error) {
+	peerIDs := make([]primitive.ObjectID, 0, len(messages))
+	for _, message := range messages {
+		peerIDs = append(peerIDs, conversationPeer(message, userID))
+	}
+	cursor, err := utils.DB.Collection("users").Find(ctx, bson.M{"_id": bson.M{"$in": peerIDs}})
+	if err != nil {
+		return nil, err
+	}
+	var peers []models.User
+	if err := cursor.All(ctx, &peers); err != nil {
+		return nil, err
+	}
+	profiles := make(map[primitive.ObjectID]models.PublicProfile, len(peers))
+	for _, peer := range peers {
+		profiles[peer.ID] = publicProfile(peer)
+	}
+
+	results := make([]models.SearchResult, 0, len(messages))
+	for i, message := range messages {
+		result := models.SearchResult{Message: message, ConversationID: peerIDs[i]}
+		if profile, ok := profiles[peerIDs[i]]; ok {
+			result.Peer = &profile
+		}
+		if around > 0 {
+			surrounding, err := messageContext(ctx, message, around)
+			if err != nil {
+				return nil, err
+			}
+			result.Context = surrounding
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// conversationPeer returns the other participant of the user's message.
+func conversationPeer(message models.Message, userID primitive.ObjectID) primitive.ObjectID {
+	if message.SenderID == userID {
+		return message.ReceiverID
+	}
+	return message.SenderID
+}
+
+// messageContext loads up to n messages either side of message in its
+// conversation.
+func messageContext(ctx context.Context, message models.Message, n int) (*models.SearchContext, error) {
+	conversation := bson.M{
+		"$or": []bson.M{
+			{"senderID": message.SenderID, "receiverID": message.ReceiverID},
+			{"senderID": message.ReceiverID, "receiverID": message.SenderID},
+		},
+	}
+
+	// Messages sent in the same instant are ordered by ID
+	before, err := findMessages(ctx,
+		bson.M{"$and": []bson.M{conversation, {"$or": []bson.M{
+			{"createdAt": bson.M{"$lt": message.CreatedAt}},
+			{"createdAt": message.CreatedAt, "_id": bson.M{"$lt": message.ID}},
+		}}}},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}}).SetLimit(int64(n)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(before)-1; i < j; i, j = i+1, j-1 {
+		before[i], before[j] = before[j], before[i]
+	}
+
+	after, err := findMessages(ctx,
+		bson.M{"$and": []bson.M{conversation, {"$or": []bson.M{
+			{"createdAt": bson.M{"$gt": message.CreatedAt}},
+			{"createdAt": message.CreatedAt, "_id": bson.M{"$gt": message.ID}},
+		}}}},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}, {Key: "_id", Value: 1}}).SetLimit(int64(n)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if before == nil {
+		before = []models.Message{}
+	}
+	if after == nil {
+		after = []models.Message{}
+	}
+	return &models.SearchContext{Before: before, After: after}, nil
+}
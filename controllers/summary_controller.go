@@ -0,0 +1,362 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/llm"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// summaryMaxMessages is the most messages one summary covers; longer
+	// runs are cut to the latest ones.
+	summaryMaxMessages = 2000
+	// summaryChunkChars is roughly how much conversation text is given to
+	// the model in one call. Longer histories are summarised in chunks
+	// whose summaries are then combined.
+	summaryChunkChars = 12000
+	// summaryConcurrency is how many chunks are summarised at once.
+	summaryConcurrency = 3
+)
+
+const summaryInstructions = "You summarise a chat conversation for \"me\", who has been away. Lines are labelled " +
+	"\"me\" or \"them\" with the person's name. Write a short summary, the most important points as short " +
+	"highlights, and any action items: things someone asked for, promised or agreed to do. Give each action " +
+	"item's assignee as \"me\", \"them\" or a name, or an empty string when it isn't clear. Write in the " +
+	"language the conversation is in."
+
+const summaryReduceInstructions = "You combine summaries of consecutive parts of one chat conversation, oldest " +
+	"first, into a single summary for \"me\", who has been away. Merge repeated highlights and action items and " +
+	"drop action items a later part shows were done. Keep each action item's assignee (\"me\", \"them\", a name " +
+	"or an empty string). Write in the language the summaries are in."
+
+// summaryOutput is the JSON the model must answer with.
+type summaryOutput struct {
+	Summary     string              `json:"summary"`
+	Highlights  []string            `json:"highlights"`
+	ActionItems []models.ActionItem `json:"actionItems"`
+}
+
+// SummarizeConversation catches the caller up on a conversation: the
+// messages since the oldest one they haven't read, or those in a date range.
+// Summaries are cached per reader by the first and last message covered.
+func SummarizeConversation(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	peerID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil || peerID == currentUser {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid conversation ID",
+			Data:         nil,
+		})
+		return
+	}
+
+	var req models.SummaryRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{
+				ResponseCode: http.StatusBadRequest,
+				Message:      "Invalid request: from and to must be RFC 3339 times",
+				Data:         nil,
+			})
+			return
+		}
+	}
+	if req.From != nil && req.To != nil && req.From.After(*req.To) {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "from must be before to",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	messages, err := summaryMessages(ctx, currentUser, peerID, req)
+	if err != nil {
+		summaryError(c, err)
+		return
+	}
+	if messages == nil {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "Conversation not found",
+			Data:         nil,
+		})
+		return
+	}
+	if len(messages) == 0 {
+		c.JSON(http.StatusOK, models.Response{
+			ResponseCode: http.StatusOK,
+			Message:      "Nothing to catch up on",
+			Data: gin.H{
+				"summary": models.ConversationSummary{Highlights: []string{}, ActionItems: []models.ActionItem{}},
+				"cached":  false,
+			},
+		})
+		return
+	}
+
+	first, last := messages[0].ID, messages[len(messages)-1].ID
+	key := currentUser.Hex() + ":" + first.Hex() + ":" + last.Hex()
+
+	var summary models.ConversationSummary
+	err = utils.DB.Collection("conversation_summaries").FindOne(ctx, bson.M{"key": key}).Decode(&summary)
+	if err == nil {
+		c.JSON(http.StatusOK, models.Response{
+			ResponseCode: http.StatusOK,
+			Message:      "Summary fetched successfully",
+			Data:         gin.H{"summary": summary, "cached": true},
+		})
+		return
+	}
+	if err != mongo.ErrNoDocuments {
+		summaryError(c, err)
+		return
+	}
+
+	history, err := contextMessages(ctx, currentUser, peerID, messages)
+	if err != nil {
+		summaryError(c, err)
+		return
+	}
+	output, err := summarise(llm.WithUser(ctx, currentUser.Hex()), history)
+	if errors.Is(err, llm.ErrNotConfigured) {
+		log.Printf("[ERROR] No LLM provider configured for summaries")
+		c.JSON(http.StatusServiceUnavailable, models.Response{
+			ResponseCode: http.StatusServiceUnavailable,
+			Message:      "Summaries are not available",
+			Data:         nil,
+		})
+		return
+	}
+	if aiQuotaExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		summaryError(c, err)
+		return
+	}
+
+	summary = models.ConversationSummary{
+		Key:            key,
+		UserID:         currentUser,
+		FirstMessageID: first,
+		LastMessageID:  last,
+		MessageCount:   len(messages),
+		Summary:        output.Summary,
+		Highlights:     output.Highlights,
+		ActionItems:    output.ActionItems,
+		CreatedAt:      time.Now(),
+	}
+	_, err = utils.DB.Collection("conversation_summaries").ReplaceOne(ctx,
+		bson.M{"key": key},
+		summary,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to cache summary for user %s: %v", currentUser.Hex(), err)
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Summary generated successfully",
+		Data:         gin.H{"summary": summary, "cached": false},
+	})
+}
+
+func summaryError(c *gin.Context, err error) {
+	log.Printf("[ERROR] Failed to summarise conversation: %v", err)
+	c.JSON(http.StatusInternalServerError, models.Response{
+		ResponseCode: http.StatusInternalServerError,
+		Message:      "Failed to summarise conversation",
+		Data:         nil,
+	})
+}
+
+// summaryMessages loads the messages a summary request covers, oldest
+// first. It returns nil when the user has no conversation with the peer and
+// an empty slice when there is nothing to summarise.
+func summaryMessages(ctx context.Context, userID, peerID primitive.ObjectID, req models.SummaryRequest) ([]models.Message, error) {
+	blockedByMe, blockedMe, err := blockBetween(ctx, userID, peerID)
+	if err != nil {
+		return nil, err
+	}
+	if blockedByMe || blockedMe {
+		return nil, nil
+	}
+
+	conversation := bson.M{
+		"$or": []bson.M{
+			{"senderID": userID, "receiverID": peerID},
+			{"senderID": peerID, "receiverID": userID},
+		},
+	}
+	exists, err := utils.DB.Collection("messages").CountDocuments(ctx, conversation, options.Count().SetLimit(1))
+	if err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, nil
+	}
+
+	createdAt := bson.M{}
+	if req.From != nil || req.To != nil {
+		if req.From != nil {
+			createdAt["$gte"] = *req.From
+		}
+		if req.To != nil {
+			createdAt["$lte"] = *req.To
+		}
+	} else {
+		var oldestUnread models.Message
+		err := utils.DB.Collection("messages").FindOne(ctx,
+			bson.M{"senderID": peerID, "receiverID": userID, "seen": false},
+			options.FindOne().SetSort(bson.M{"createdAt": 1}),
+		).Decode(&oldestUnread)
+		if err == mongo.ErrNoDocuments {
+			return []models.Message{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		createdAt["$gte"] = oldestUnread.CreatedAt
+	}
+
+	// The newest messages matter most when there are too many
+	filter := bson.M{"$and": []bson.M{conversation, {"createdAt": createdAt}}}
+	cursor, err := utils.DB.Collection("messages").Find(ctx, filter,
+		options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(summaryMaxMessages),
+	)
+	if err != nil {
+		return nil, err
+	}
+	messages := []models.Message{}
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// summarise summarises the history in one call when it fits, otherwise
+// summarises each chunk and then combines those summaries.
+func summarise(ctx context.Context, history []contextMessage) (summaryOutput, error) {
+	chunks := chunkHistory(history, summaryChunkChars)
+	if len(chunks) == 1 {
+		return summariseText(ctx, summaryInstructions, chunks[0])
+	}
+
+	partials := make([]summaryOutput, len(chunks))
+	errs := make([]error, len(chunks))
+	sem := make(chan struct{}, summaryConcurrency)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			partials[i], errs[i] = summariseText(ctx, summaryInstructions, chunk)
+		}(i, chunk)
+	}
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return summaryOutput{}, err
+	}
+
+	var combined strings.Builder
+	for i, partial := range partials {
+		fmt.Fprintf(&combined, "Part %d:\nSummary: %s\nHighlights:\n", i+1, partial.Summary)
+		for _, highlight := range partial.Highlights {
+			combined.WriteString("- " + highlight + "\n")
+		}
+		combined.WriteString("Action items:\n")
+		for _, item := range partial.ActionItems {
+			fmt.Fprintf(&combined, "- %s (assignee: %q)\n", item.Text, item.Assignee)
+		}
+		combined.WriteString("\n")
+	}
+	return summariseText(ctx, summaryReduceInstructions, combined.String())
+}
+
+func summariseText(ctx context.Context, instructions, text string) (summaryOutput, error) {
+	var output summaryOutput
+	_, err := llm.GenerateJSON(ctx, llm.FeatureSummaries, llm.Request{
+		System:   instructions,
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: text}},
+		Schema:   summarySchema(),
+	}, &output)
+	if output.Highlights == nil {
+		output.Highlights = []string{}
+	}
+	if output.ActionItems == nil {
+		output.ActionItems = []models.ActionItem{}
+	}
+	return output, err
+}
+
+// chunkHistory renders the history as timestamped lines split into chunks
+// of about maxChars. A single longer message gets a chunk of its own.
+func chunkHistory(history []contextMessage, maxChars int) []string {
+	var chunks []string
+	var chunk strings.Builder
+	for _, msg := range history {
+		line := fmt.Sprintf("[%s] %s (%s): %s\n", msg.At.UTC().Format("2006-01-02 15:04"), msg.Role, msg.Name, msg.Content)
+		if chunk.Len() > 0 && chunk.Len()+len(line) > maxChars {
+			chunks = append(chunks, chunk.String())
+			chunk.Reset()
+		}
+		chunk.WriteString(line)
+	}
+	if chunk.Len() > 0 {
+		chunks = append(chunks, chunk.String())
+	}
+	return chunks
+}
+
+func summarySchema() *llm.Schema {
+	closed := false
+	return &llm.Schema{
+		Type: llm.TypeObject,
+		Properties: map[string]*llm.Schema{
+			"summary": {Type: llm.TypeString},
+			"highlights": {
+				Type:  llm.TypeArray,
+				Items: &llm.Schema{Type: llm.TypeString},
+			},
+			"actionItems": {
+				Type: llm.TypeArray,
+				Items: &llm.Schema{
+					Type: llm.TypeObject,
+					Properties: map[string]*llm.Schema{
+						"text":     {Type: llm.TypeString},
+						"assignee": {Type: llm.TypeString},
+					},
+					Required:             []string{"text", "assignee"},
+					AdditionalProperties: &closed,
+				},
+			},
+		},
+		Required:             []string{"summary", "highlights", "actionItems"},
+		AdditionalProperties: &closed,
+	}
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChunkHistory(t *testing.T) {
+	at := time.Date(2024, 5, 1, 9, 30, 0, 0, time.UTC)
+	message := func(content string) contextMessage {
+		return contextMessage{Role: "them", Name: "Sam", Content: content, At: at}
+	}
+	// Every line is this long plus its content
+	prefix := len("[2024-05-01 09:30] them (Sam): \n")
+
+	tests := []struct {
+		name     string
+		history  []contextMessage
+		maxChars int
+		want     []int
+	}{
+		{"empty", nil, 100, nil},
+		{"fits in one", []contextMessage{message("hi"), message("hello")}, 100, []int{2}},
+		{"splits", []contextMessage{message("aaaa"), message("bbbb"), message("cccc")}, 2*prefix + 8, []int{2, 1}},
+		{"exactly full", []contextMessage{message("aaaa"), message("bbbb")}, 2*prefix + 8, []int{2}},
+		{"long message alone", []contextMessage{message("hi"), message(strings.Repeat("x", 200)), message("bye")}, 100, []int{1, 1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := chunkHistory(tt.history, tt.maxChars)
+			if len(chunks) != len(tt.want) {
+				t.Fatalf("got %d chunks %q, want %d", len(chunks), chunks, len(tt.want))
+			}
+			for i, chunk := range chunks {
+				if lines := strings.Count(chunk, "\n"); lines != tt.want[i] {
+					t.Errorf("chunk %d has %d lines, want %d", i, lines, tt.want[i])
+				}
+			}
+		})
+	}
+
+	line := chunkHistory([]contextMessage{message("hi")}, 100)[0]
+	if want := "[2024-05-01 09:30] them (Sam): hi\n"; line != want {
+		t.Errorf("line = %q, want %q", line, want)
+	}
+}
@@ -0,0 +1,320 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/llm"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// autoTranslateMax is the most untranslated messages one fetch
+	// translates, newest first; older ones are translated by later fetches
+	autoTranslateMax = 50
+	// autoTranslateConcurrency is how many messages are translated at once.
+	autoTranslateConcurrency = 4
+	// autoTranslateTimeout bounds translating the messages of one fetch.
+	autoTranslateTimeout = 2 * time.Minute
+)
+
+const translationInstructions = "You translate chat messages. Translate the message into the language with " +
+	"ISO 639-1 code %s, keeping its meaning, tone, emoji and formatting. Don't translate names, links or code. " +
+	"Give the ISO 639-1 code of the language the message is written in, and when it is already in the target " +
+	"language return it unchanged."
+
+// languageCode matches an ISO 639-1 code with an optional region, as in
+// "en" or "pt-BR".
+var languageCode = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+
+// translationOutput is the JSON the model must answer with.
+type translationOutput struct {
+	SourceLanguage string `json:"sourceLanguage"`
+	Translation    string `json:"translation"`
+}
+
+// TranslateMessage translates a message the caller sent or received into
+// the language given by ?to=. Translations are stored, so asking again
+// costs nothing.
+func TranslateMessage(c *gin.Context) {
+	currentUser := utils.ObjectIDFromHex(c.GetString("userID"))
+	messageID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid message ID",
+			Data:         nil,
+		})
+		return
+	}
+	language, ok := normaliseLanguage(c.Query("to"))
+	if !ok {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "to must be a language code such as en or pt-BR",
+			Data:         nil,
+		})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var message models.Message
+	err = utils.DB.Collection("messages").FindOne(ctx, bson.M{
+		"_id": messageID,
+		"$or": []bson.M{{"senderID": currentUser}, {"receiverID": currentUser}},
+	}).Decode(&message)
+	if err == mongo.ErrNoDocuments {
+		c.JSON(http.StatusNotFound, models.Response{
+			ResponseCode: http.StatusNotFound,
+			Message:      "Message not found",
+			Data:         nil,
+		})
+		return
+	}
+	if err != nil {
+		translationError(c, err)
+		return
+	}
+
+	translations, err := findTranslations(ctx, []primitive.ObjectID{messageID}, language)
+	if err != nil {
+		translationError(c, err)
+		return
+	}
+	if translation, ok := translations[messageID]; ok {
+		c.JSON(http.StatusOK, models.Response{
+			ResponseCode: http.StatusOK,
+			Message:      "Translation fetched successfully",
+			Data:         gin.H{"translation": translation, "cached": true},
+		})
+		return
+	}
+
+	translation, err := translateMessage(llm.WithUser(ctx, currentUser.Hex()), message, language)
+	if errors.Is(err, llm.ErrNotConfigured) {
+		log.Printf("[ERROR] No LLM provider configured for translation")
+		c.JSON(http.StatusServiceUnavailable, models.Response{
+			ResponseCode: http.StatusServiceUnavailable,
+			Message:      "Translation is not available",
+			Data:         nil,
+		})
+		return
+	}
+	if aiQuotaExceeded(c, err) {
+		return
+	}
+	if err != nil {
+		translationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Message translated successfully",
+		Data:         gin.H{"translation": translation, "cached": false},
+	})
+}
+
+func translationError(c *gin.Context, err error) {
+	log.Printf("[ERROR] Failed to translate message: %v", err)
+	c.JSON(http.StatusInternalServerError, models.Response{
+		ResponseCode: http.StatusInternalServerError,
+		Message:      "Failed to translate message",
+		Data:         nil,
+	})
+}
+
+// normaliseLanguage checks a language code and lower-cases its language
+// part, so "EN" and "en" share translations.
+func normaliseLanguage(code string) (string, bool) {
+	code = strings.TrimSpace(code)
+	if i := strings.IndexByte(code, '-'); i >= 0 {
+		code = strings.ToLower(code[:i]) + code[i:]
+	} else {
+		code = strings.ToLower(code)
+	}
+	return code, languageCode.MatchString(code)
+}
+
+// findTranslations returns the stored translations of the messages into the
+// language by message ID.
+func findTranslations(ctx context.Context, ids []primitive.ObjectID, language string) (map[primitive.ObjectID]models.MessageTranslation, error) {
+	cursor, err := utils.DB.Collection("message_translations").Find(ctx, bson.M{
+		"messageId": bson.M{"$in": ids},
+		"language":  language,
+	})
+	if err != nil {
+		return nil, err
+	}
+	var translations []models.MessageTranslation
+	if err := cursor.All(ctx, &translations); err != nil {
+		return nil, err
+	}
+
+	byMessage := make(map[primitive.ObjectID]models.MessageTranslation, len(translations))
+	for _, translation := range translations {
+		byMessage[translation.MessageID] = translation
+	}
+	return byMessage, nil
+}
+
+// translateMessage translates the message with the model and stores the
+// result.
+func translateMessage(ctx context.Context, message models.Message, language string) (models.MessageTranslation, error) {
+	var output translationOutput
+	_, err := llm.GenerateJSON(ctx, llm.FeatureTranslation, llm.Request{
+		System:   fmt.Sprintf(translationInstructions, language),
+		Messages: []llm.Message{{Role: llm.RoleUser, Content: message.Content}},
+		Schema:   translationSchema(),
+	}, &output)
+	if err != nil {
+		return models.MessageTranslation{}, err
+	}
+
+	translation := models.MessageTranslation{
+		MessageID:      message.ID,
+		Language:       language,
+		SourceLanguage: output.SourceLanguage,
+		Text:           strings.TrimSpace(output.Translation),
+		CreatedAt:      time.Now(),
+	}
+	_, err = utils.DB.Collection("message_translations").ReplaceOne(ctx,
+		bson.M{"messageId": message.ID, "language": language},
+		translation,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil {
+		log.Printf("[ERROR] Failed to store translation of message %s: %v", message.ID.Hex(), err)
+	}
+	return translation, nil
+}
+
+// attachTranslations attaches stored translations of the peer's messages
+// into the reader's auto-translate language, when they have one for the
+// conversation. Missing ones are generated in the background for the newest
+// messages and sent over the conversation channel as they are done. Failures
+// only leave messages untranslated.
+func attachTranslations(ctx context.Context, userID, peerID primitive.ObjectID, messages []models.Message) {
+	settings, err := loadConversationSettings(ctx, userID, peerID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load conversation settings: %v", err)
+		return
+	}
+	if settings.AutoTranslate == "" {
+		return
+	}
+
+	var ids []primitive.ObjectID
+	for _, message := range messages {
+		if message.SenderID == peerID && message.Content != "" {
+			ids = append(ids, message.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	translations, err := findTranslations(ctx, ids, settings.AutoTranslate)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load translations: %v", err)
+		return
+	}
+
+	if missing := applyTranslations(messages, peerID, translations); len(missing) > 0 {
+		go translateMissing(userID, peerID, settings.AutoTranslate, missing)
+	}
+}
+
+// applyTranslations attaches the stored translations to the peer's messages
+// and returns those still to be translated, newest first and at most
+// autoTranslateMax of them.
+func applyTranslations(messages []models.Message, peerID primitive.ObjectID, translations map[primitive.ObjectID]models.MessageTranslation) []models.Message {
+	var missing []models.Message
+	for i := len(messages) - 1; i >= 0; i-- {
+		message := messages[i]
+		if message.SenderID != peerID || message.Content == "" {
+			continue
+		}
+		if translation, ok := translations[message.ID]; ok {
+			messages[i].Translation = &translation
+		} else if len(missing) < autoTranslateMax {
+			missing = append(missing, message)
+		}
+	}
+	return missing
+}
+
+// translateMissing translates messages for the reader and sends each
+// translation as a "message-translated" event. Every translation counts
+// against the reader's AI quota; once it is used up the rest are left.
+func translateMissing(userID, peerID primitive.ObjectID, language string, messages []models.Message) {
+	ctx, cancel := context.WithTimeout(context.Background(), autoTranslateTimeout)
+	defer cancel()
+	ctx = llm.WithUser(ctx, userID.Hex())
+
+	channel := utils.ConversationChannel(userID, peerID)
+	sem := make(chan struct{}, autoTranslateConcurrency)
+	var wg sync.WaitGroup
+	for _, message := range messages {
+		wg.Add(1)
+		go func(message models.Message) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if ctx.Err() != nil {
+				return
+			}
+
+			translation, err := translateMessage(ctx, message, language)
+			var quotaErr *aiQuotaError
+			if errors.As(err, &quotaErr) {
+				cancel()
+				return
+			}
+			if err != nil {
+				log.Printf("[WARN] Failed to auto-translate message %s: %v", message.ID.Hex(), err)
+				return
+			}
+
+			// Both members share the channel, so the reader is named
+			err = utils.PusherClient.Trigger(channel, "message-translated", gin.H{
+				"userId":      userID.Hex(),
+				"translation": translation,
+			})
+			if err != nil {
+				log.Printf("[ERROR] Failed to send translation of message %s: %v", message.ID.Hex(), err)
+			}
+		}(message)
+	}
+	wg.Wait()
+}
+
+func translationSchema() *llm.Schema {
+	closed := false
+	return &llm.Schema{
+		Type: llm.TypeObject,
+		Properties: map[string]*llm.Schema{
+			"sourceLanguage": {
+				Type:        llm.TypeString,
+				Description: "ISO 639-1 code of the message's language",
+			},
+			"translation": {Type: llm.TypeString},
+		},
+		Required:             []string{"sourceLanguage", "translation"},
+		AdditionalProperties: &closed,
+	}
+}
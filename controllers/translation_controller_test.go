@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/sajanIocod/chat_backend/models"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestNormaliseLanguage(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+		ok   bool
+	}{
+		{"en", "en", true},
+		{"EN", "en", true},
+		{" de ", "de", true},
+		{"pt-BR", "pt-BR", true},
+		{"PT-BR", "pt-BR", true},
+		{"zh-Hant", "zh-Hant", true},
+		{"fil", "fil", true},
+		{"", "", false},
+		{"e", "e", false},
+		{"english", "english", false},
+		{"en_US", "en_us", false},
+		{"en-", "en-", false},
+		{"en-US-x", "en-US-x", false},
+	}
+	for _, tt := range tests {
+		got, ok := normaliseLanguage(tt.code)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normaliseLanguage(%q) = %q, %v, want %q, %v", tt.code, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestApplyTranslations(t *testing.T) {
+	me, peer := primitive.NewObjectID(), primitive.NewObjectID()
+	message := func(sender primitive.ObjectID, content string) models.Message {
+		return models.Message{ID: primitive.NewObjectID(), SenderID: sender, ReceiverID: me, Content: content}
+	}
+	messages := []models.Message{
+		message(peer, "hola"),
+		message(me, "hi"),
+		message(peer, "qué tal"),
+		message(peer, ""),
+		message(peer, "hasta luego"),
+	}
+	stored := models.MessageTranslation{MessageID: messages[2].ID, Language: "en", Text: "how are you"}
+	translations := map[primitive.ObjectID]models.MessageTranslation{stored.MessageID: stored}
+
+	missing := applyTranslations(messages, peer, translations)
+
+	if got := messages[2].Translation; got == nil || got.Text != "how are you" {
+		t.Errorf("stored translation not attached: %+v", got)
+	}
+	for _, i := range []int{0, 1, 3, 4} {
+		if messages[i].Translation != nil {
+			t.Errorf("message %d has translation %+v, want none", i, messages[i].Translation)
+		}
+	}
+	if len(missing) != 2 || missing[0].ID != messages[4].ID || missing[1].ID != messages[0].ID {
+		t.Errorf("missing = %+v, want the peer's untranslated messages newest first", missing)
+	}
+
+	many := make([]models.Message, autoTranslateMax+5)
+	for i := range many {
+		many[i] = message(peer, "hola")
+	}
+	if missing := applyTranslations(many, peer, nil); len(missing) != autoTranslateMax || missing[0].ID != many[len(many)-1].ID {
+		t.Errorf("got %d missing, want the newest %d", len(missing), autoTranslateMax)
+	}
+}
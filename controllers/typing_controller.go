@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sajanIocod/chat_backend/models"
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// typingThrottle is the minimum gap between relayed typing events for
+	// the same conversation; keystrokes in between only extend the timeout.
+	typingThrottle = 3 * time.Second
+	// typingTimeout is how long a typing indicator lasts without a refresh.
+	typingTimeout = 5 * time.Second
+)
+
+// typingState is the in-memory record of a user typing to a peer. Typing
+// indicators are never persisted.
+type typingState struct {
+	lastSent time.Time
+	stop     *time.Timer
+}
+
+var (
+	typingMu     sync.Mutex
+	typingStates = map[string]*typingState{}
+)
+
+// SetTyping relays a typing indicator to the other participant of a
+// conversation. The conversation is identified by the peer's user ID.
+func SetTyping(c *gin.Context) {
+	senderID := utils.ObjectIDFromHex(c.GetString("userID"))
+	peerID, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil || peerID == senderID {
+		c.JSON(http.StatusBadRequest, models.Response{
+			ResponseCode: http.StatusBadRequest,
+			Message:      "Invalid conversation ID",
+			Data:         nil,
+		})
+		return
+	}
+
+	var req models.TypingRequest
+	// An empty body means the user is typing
+	req.Typing = true
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{
+				ResponseCode: http.StatusBadRequest,
+				Message:      "Invalid request",
+				Data:         nil,
+			})
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	blockedByMe, blockedMe, err := blockBetween(ctx, senderID, peerID)
+	if err != nil {
+		log.Printf("[ERROR] Failed to check blocks for typing: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Failed to relay typing status",
+			Data:         nil,
+		})
+		return
+	}
+	if blockedByMe {
+		c.JSON(http.StatusForbidden, models.Response{
+			ResponseCode: http.StatusForbidden,
+			Message:      "Unblock this user to send them typing status",
+			Data:         nil,
+		})
+		return
+	}
+
+	// When the peer has blocked the sender nothing is relayed, but the
+	// answer is the same so the block isn't revealed
+	if req.Typing {
+		// Suggestions are stale once the user writes their own reply
+		cancelSuggestionStream(senderID, peerID)
+		if !blockedMe {
+			startTyping(senderID, peerID)
+		}
+	} else if !blockedMe {
+		stopTyping(senderID, peerID)
+	}
+
+	c.JSON(http.StatusOK, models.Response{
+		ResponseCode: http.StatusOK,
+		Message:      "Typing status relayed",
+		Data: gin.H{
+			"typing":    req.Typing,
+			"expiresIn": int(typingTimeout.Seconds()),
+		},
+	})
+}
+
+func typingKey(senderID, peerID primitive.ObjectID) string {
+	return senderID.Hex() + ":" + peerID.Hex()
+}
+
+func startTyping(senderID, peerID primitive.ObjectID) {
+	key := typingKey(senderID, peerID)
+
+	typingMu.Lock()
+	state, ok := typingStates[key]
+	if !ok {
+		state = &typingState{}
+		typingStates[key] = state
+	}
+	send := time.Since(state.lastSent) >= typingThrottle
+	if send {
+		state.lastSent = time.Now()
+	}
+	if state.stop != nil {
+		state.stop.Stop()
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(typingTimeout, func() {
+		expireTyping(senderID, peerID, timer)
+	})
+	state.stop = timer
+	typingMu.Unlock()
+
+	if send {
+		relayTyping(senderID, peerID, true)
+	}
+}
+
+func stopTyping(senderID, peerID primitive.ObjectID) {
+	key := typingKey(senderID, peerID)
+
+	typingMu.Lock()
+	state, ok := typingStates[key]
+	if ok {
+		if state.stop != nil {
+			state.stop.Stop()
+		}
+		delete(typingStates, key)
+	}
+	typingMu.Unlock()
+
+	// Nothing to stop if the peer was never told the user is typing
+	if ok {
+		relayTyping(senderID, peerID, false)
+	}
+}
+
+// expireTyping stops the indicator when its timeout fires, unless a later
+// keystroke has already replaced the timer.
+func expireTyping(senderID, peerID primitive.ObjectID, timer *time.Timer) {
+	typingMu.Lock()
+	state, ok := typingStates[typingKey(senderID, peerID)]
+	current := ok && state.stop == timer
+	typingMu.Unlock()
+
+	if current {
+		stopTyping(senderID, peerID)
+	}
+}
+
+func relayTyping(senderID, peerID primitive.ObjectID, typing bool) {
+	eventData := gin.H{
+		"userId":    senderID.Hex(),
+		"typing":    typing,
+		"expiresIn": int(typingTimeout.Seconds()),
+	}
+
+	err := utils.PusherClient.Trigger(utils.UserChannel(peerID.Hex()), "typing", eventData)
+	if err != nil {
+		log.Printf("[ERROR] Failed to trigger typing event: %v", err)
+	}
+}
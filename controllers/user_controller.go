@@ -11,6 +11,7 @@ import (
 	"github.com/sajanIocod/chat_backend/models"
 	"github.com/sajanIocod/chat_backend/utils"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
@@ -31,9 +32,27 @@ func GetUsers(c *gin.Context) {
 	}
 	skip := (page - 1) * limit
 
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Blocks hide users from each other in both directions
+	blockedIDs, err := blockedUserIDs(ctx, utils.ObjectIDFromHex(userID))
+	if err != nil {
+		log.Printf("[ERROR] Failed to fetch blocked users: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error fetching users",
+			Data:         nil,
+		})
+		return
+	}
+
 	// Build MongoDB filter
 	filter := bson.M{
-		"_id": bson.M{"$ne": utils.ObjectIDFromHex(userID)}, // Exclude current user
+		"_id": bson.M{
+			"$ne":  utils.ObjectIDFromHex(userID), // Exclude current user
+			"$nin": blockedIDs,
+		},
 	}
 
 	// Add search filter only if search parameter is provided
@@ -45,9 +64,6 @@ func GetUsers(c *gin.Context) {
 		log.Printf("[INFO] Applying search filter for username: %s", search)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
-
 	// MongoDB find with skip and limit
 	findOptions := options.Find().
 		SetSkip(int64(skip)).
@@ -89,6 +105,21 @@ func GetUsers(c *gin.Context) {
 		return
 	}
 
+	userIDs := make([]primitive.ObjectID, 0, len(users))
+	for _, u := range users {
+		userIDs = append(userIDs, u.ID)
+	}
+	presence, err := loadPresence(ctx, userIDs)
+	if err != nil {
+		log.Printf("[ERROR] Failed to load presence: %v", err)
+		c.JSON(http.StatusInternalServerError, models.Response{
+			ResponseCode: http.StatusInternalServerError,
+			Message:      "Error fetching presence",
+			Data:         nil,
+		})
+		return
+	}
+
 	// Format response with unread message counts
 	userList := make([]gin.H, 0, len(users))
 	for _, u := range users {
@@ -112,7 +143,10 @@ func GetUsers(c *gin.Context) {
 			"id":          u.ID.Hex(),
 			"username":    u.Username,
 			"email":       u.Email,
+			"isBot":       u.IsBot,
 			"unreadCount": unreadCount,
+			"online":      presence[u.ID].Online,
+			"lastSeenAt":  lastSeenFor(u, presence[u.ID]),
 		})
 	}
 
@@ -0,0 +1,87 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"math"
+)
+
+// ErrNoEmbeddings is returned when a feature's provider can't embed text.
+var ErrNoEmbeddings = errors.New("llm: provider does not support embeddings")
+
+// EmbedRequest asks for one vector per text.
+type EmbedRequest struct {
+	// Model is filled in from the feature's configuration when empty
+	Model string
+	Texts []string
+	// Query marks the texts as search queries rather than documents to be
+	// searched, for providers that embed the two differently
+	Query bool
+}
+
+// EmbedResponse holds the vectors in the order of the request's texts.
+type EmbedResponse struct {
+	Vectors [][]float32
+	Model   string
+	Usage   Usage
+}
+
+// Embedder is implemented by providers that can turn text into vectors.
+type Embedder interface {
+	Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error)
+}
+
+// Embed runs req on the provider configured for feature, bounded by the
+// feature's timeout. Embeddings are never cached.
+func Embed(ctx context.Context, feature string, req EmbedRequest) (EmbedResponse, error) {
+	r, ok := routes[feature]
+	if !ok {
+		return EmbedResponse{}, ErrNotConfigured
+	}
+	embedder, ok := r.provider.(Embedder)
+	if !ok {
+		return EmbedResponse{}, ErrNoEmbeddings
+	}
+	if req.Model == "" {
+		req.Model = r.model
+	}
+
+	if err := admitCall(ctx, feature); err != nil {
+		return EmbedResponse{}, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	resp, err := embedder.Embed(callCtx, req)
+	record(ctx, feature, req.Model, Response{Model: resp.Model, Usage: resp.Usage}, false)
+	if err != nil {
+		return resp, err
+	}
+	for i, vector := range resp.Vectors {
+		resp.Vectors[i] = Normalize(vector)
+	}
+	return resp, nil
+}
+
+// Configured reports whether a provider is set up for feature.
+func Configured(feature string) bool {
+	_, ok := routes[feature]
+	return ok
+}
+
+// Normalize scales vector to unit length in place, so cosine similarity is
+// a dot product. A zero vector is returned as it is.
+func Normalize(vector []float32) []float32 {
+	var sum float64
+	for _, v := range vector {
+		sum += float64(v) * float64(v)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
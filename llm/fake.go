@@ -0,0 +1,161 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Fake is an in-process provider with deterministic output. Unless a reply
+// function is set it answers with a numbered list echoing the last message,
+// and it records every request it receives.
+type Fake struct {
+	mu       sync.Mutex
+	requests []Request
+	reply    func(Request) string
+}
+
+func NewFake() *Fake {
+	return &Fake{}
+}
+
+func (f *Fake) Generate(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	reply := f.reply
+	f.mu.Unlock()
+
+	text := fakeReply(req)
+	if req.Schema != nil {
+		example, _ := json.Marshal(fakeValue(req.Schema, ""))
+		text = string(example)
+	}
+	if reply != nil {
+		text = reply(req)
+	}
+
+	input := len(strings.Fields(req.System))
+	for _, msg := range req.Messages {
+		input += len(strings.Fields(msg.Content))
+	}
+	return Response{
+		Text:  text,
+		Model: req.Model,
+		Usage: Usage{InputTokens: input, OutputTokens: len(strings.Fields(text))},
+	}, nil
+}
+
+// Stream delivers the same text as Generate one line at a time.
+func (f *Fake) Stream(ctx context.Context, req Request, onText func(string) error) (Response, error) {
+	resp, err := f.Generate(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	for _, line := range strings.SplitAfter(resp.Text, "\n") {
+		if line == "" {
+			continue
+		}
+		if err := onText(line); err != nil {
+			return Response{}, err
+		}
+	}
+	return resp, nil
+}
+
+// fakeDimensions is the length of the Fake's embeddings.
+const fakeDimensions = 64
+
+// Embed hashes each word of a text into a bucket of its vector, so texts
+// sharing words come out close together.
+func (f *Fake) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return EmbedResponse{}, err
+	}
+
+	resp := EmbedResponse{Model: req.Model, Vectors: make([][]float32, 0, len(req.Texts))}
+	for _, text := range req.Texts {
+		vector := make([]float32, fakeDimensions)
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+		})
+		for _, word := range words {
+			h := fnv.New32a()
+			h.Write([]byte(word))
+			vector[h.Sum32()%fakeDimensions]++
+		}
+		resp.Vectors = append(resp.Vectors, Normalize(vector))
+		resp.Usage.InputTokens += len(words)
+	}
+	return resp, nil
+}
+
+// SetReply makes later calls answer with fn(req).
+func (f *Fake) SetReply(fn func(Request) string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.reply = fn
+}
+
+// Requests returns a copy of every request received so far.
+func (f *Fake) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+func fakeReply(req Request) string {
+	last := ""
+	if len(req.Messages) > 0 {
+		last = req.Messages[len(req.Messages)-1].Content
+	}
+	words := []rune(strings.Join(strings.Fields(last), " "))
+	if len(words) > 40 {
+		words = words[:40]
+	}
+	return "1. OK\n2. Thanks!\n3. Re: " + string(words)
+}
+
+// fakeValue builds the smallest value matching the schema, naming strings
+// after where they sit so tests can tell them apart.
+func fakeValue(s *Schema, name string) interface{} {
+	switch s.Type {
+	case TypeObject:
+		obj := map[string]interface{}{}
+		for _, prop := range s.propertyNames() {
+			obj[prop] = fakeValue(s.Properties[prop], prop)
+		}
+		return obj
+	case TypeArray:
+		n := 1
+		if s.MinItems != nil {
+			n = *s.MinItems
+		}
+		items := make([]interface{}, 0, n)
+		for i := 1; i <= n; i++ {
+			item := interface{}(nil)
+			if s.Items != nil {
+				item = fakeValue(s.Items, fmt.Sprintf("%s %d", name, i))
+			}
+			items = append(items, item)
+		}
+		return items
+	case TypeString:
+		if len(s.Enum) > 0 {
+			return s.Enum[0]
+		}
+		return "fake " + name
+	case TypeNumber, TypeInteger:
+		return 0
+	case TypeBoolean:
+		return false
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/genai"
+)
+
+// Gemini generates text through the Gemini API. One client is shared by
+// every call.
+type Gemini struct {
+	client *genai.Client
+}
+
+func NewGemini(apiKey string) (*Gemini, error) {
+	if apiKey == "" {
+		return nil, errors.New("llm: GEMINI_API_KEY is not set")
+	}
+	client, err := genai.NewClient(context.Background(), &genai.ClientConfig{
+		APIKey:  apiKey,
+		Backend: genai.BackendGeminiAPI,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Gemini{client: client}, nil
+}
+
+func (g *Gemini) Generate(ctx context.Context, req Request) (Response, error) {
+	result, err := g.client.Models.GenerateContent(ctx, req.Model, geminiContents(req), geminiConfig(req))
+	if err != nil {
+		return Response{}, err
+	}
+
+	resp := Response{Text: result.Text(), Model: req.Model}
+	if result.UsageMetadata != nil {
+		resp.Usage = Usage{
+			InputTokens:  int(result.UsageMetadata.PromptTokenCount),
+			OutputTokens: int(result.UsageMetadata.CandidatesTokenCount),
+		}
+	}
+	return resp, nil
+}
+
+func (g *Gemini) Stream(ctx context.Context, req Request, onText func(string) error) (Response, error) {
+	resp := Response{Model: req.Model}
+	var text strings.Builder
+	for chunk, err := range g.client.Models.GenerateContentStream(ctx, req.Model, geminiContents(req), geminiConfig(req)) {
+		if err != nil {
+			return Response{}, err
+		}
+		// Usage is cumulative, so the last chunk's counts are the totals
+		if chunk.UsageMetadata != nil {
+			resp.Usage = Usage{
+				InputTokens:  int(chunk.UsageMetadata.PromptTokenCount),
+				OutputTokens: int(chunk.UsageMetadata.CandidatesTokenCount),
+			}
+		}
+		piece := chunk.Text()
+		if piece == "" {
+			continue
+		}
+		text.WriteString(piece)
+		if err := onText(piece); err != nil {
+			return Response{}, err
+		}
+	}
+	resp.Text = text.String()
+	return resp, nil
+}
+
+// Embed doesn't report usage; the Gemini API doesn't count tokens for
+// embeddings.
+func (g *Gemini) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	contents := make([]*genai.Content, 0, len(req.Texts))
+	for _, text := range req.Texts {
+		contents = append(contents, genai.NewContentFromText(text, genai.RoleUser))
+	}
+	task := "RETRIEVAL_DOCUMENT"
+	if req.Query {
+		task = "RETRIEVAL_QUERY"
+	}
+
+	result, err := g.client.Models.EmbedContent(ctx, req.Model, contents, &genai.EmbedContentConfig{TaskType: task})
+	if err != nil {
+		return EmbedResponse{}, err
+	}
+	if len(result.Embeddings) != len(req.Texts) {
+		return EmbedResponse{}, fmt.Errorf("llm: got %d embeddings for %d texts", len(result.Embeddings), len(req.Texts))
+	}
+
+	vectors := make([][]float32, 0, len(result.Embeddings))
+	for _, embedding := range result.Embeddings {
+		vectors = append(vectors, embedding.Values)
+	}
+	return EmbedResponse{Vectors: vectors, Model: req.Model}, nil
+}
+
+func geminiContents(req Request) []*genai.Content {
+	contents := make([]*genai.Content, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		role := genai.RoleUser
+		if msg.Role == RoleAssistant {
+			role = genai.RoleModel
+		}
+		contents = append(contents, genai.NewContentFromText(msg.Content, genai.Role(role)))
+	}
+	return contents
+}
+
+func geminiConfig(req Request) *genai.GenerateContentConfig {
+	config := &genai.GenerateContentConfig{
+		Temperature:     req.Temperature,
+		MaxOutputTokens: int32(req.MaxTokens),
+	}
+	if req.System != "" {
+		config.SystemInstruction = genai.NewContentFromText(req.System, genai.RoleUser)
+	}
+	if req.Schema != nil {
+		config.ResponseMIMEType = "application/json"
+		config.ResponseSchema = geminiSchema(req.Schema)
+	}
+	return config
+}
+
+func geminiSchema(s *Schema) *genai.Schema {
+	schema := &genai.Schema{
+		Type:        genai.Type(strings.ToUpper(s.Type)),
+		Description: s.Description,
+		Enum:        s.Enum,
+		Required:    s.Required,
+	}
+	if s.MinItems != nil {
+		n := int64(*s.MinItems)
+		schema.MinItems = &n
+	}
+	if s.MaxItems != nil {
+		n := int64(*s.MaxItems)
+		schema.MaxItems = &n
+	}
+	if s.Items != nil {
+		schema.Items = geminiSchema(s.Items)
+	}
+	if len(s.Properties) > 0 {
+		schema.Properties = map[string]*genai.Schema{}
+		for name, prop := range s.Properties {
+			schema.Properties[name] = geminiSchema(prop)
+		}
+		schema.PropertyOrdering = s.propertyNames()
+	}
+	return schema
+}
@@ -0,0 +1,265 @@
+// Package llm generates text with large language models. Each AI feature is
+// routed to a Provider (Gemini, an OpenAI-compatible server or a fake) with
+// its own model and timeout, configured from the environment.
+package llm
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// Features that use a language model
+const (
+	FeatureSuggestions = "suggestions"
+	FeatureSummaries   = "summaries"
+	FeatureBots        = "bots"
+	FeatureTranslation = "translation"
+	FeatureEmbeddings  = "embeddings"
+)
+
+// features lists every feature Init configures
+var features = []string{FeatureSuggestions, FeatureSummaries, FeatureBots, FeatureTranslation, FeatureEmbeddings}
+
+// Message roles
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// ErrNotConfigured is returned when no provider is configured for a feature.
+var ErrNotConfigured = errors.New("llm: no provider configured for feature")
+
+// defaultTimeout bounds a call when the feature sets no timeout.
+const defaultTimeout = 15 * time.Second
+
+// Message is one turn of the conversation sent to the model.
+type Message struct {
+	Role    string
+	Content string
+}
+
+// Request is a provider-independent generation request.
+type Request struct {
+	// Model is filled in from the feature's configuration when empty
+	Model       string
+	System      string
+	Messages    []Message
+	MaxTokens   int
+	Temperature *float32
+	// Schema, when set, constrains the output to JSON matching it
+	Schema *Schema
+}
+
+// Usage is the token count a provider reports for a call.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+}
+
+// Response is the text a model generated.
+type Response struct {
+	Text  string
+	Model string
+	Usage Usage
+}
+
+// Provider generates text with one model API.
+type Provider interface {
+	Generate(ctx context.Context, req Request) (Response, error)
+}
+
+// Streamer is implemented by providers that can hand out text while it is
+// still being generated.
+type Streamer interface {
+	// Stream calls onText with each new piece of text and returns the full
+	// response at the end. An error from onText stops the stream.
+	Stream(ctx context.Context, req Request, onText func(string) error) (Response, error)
+}
+
+// route is the provider, model and timeout a feature uses, and whether its
+// responses may be cached.
+type route struct {
+	provider Provider
+	model    string
+	timeout  time.Duration
+	cache    bool
+}
+
+var routes = map[string]route{}
+
+// defaultModels is used when neither LLM_<FEATURE>_MODEL nor LLM_MODEL is set
+var defaultModels = map[string]string{
+	"gemini": "gemini-2.0-flash",
+	"openai": "gpt-4o-mini",
+	"fake":   "fake",
+}
+
+// defaultEmbeddingModels replaces defaultModels for FeatureEmbeddings
+var defaultEmbeddingModels = map[string]string{
+	"gemini": "text-embedding-004",
+	"openai": "text-embedding-3-small",
+	"fake":   "fake-embedding",
+}
+
+// Init configures each feature from the environment. The provider is read
+// from LLM_<FEATURE>_PROVIDER, then LLM_PROVIDER ("gemini", "openai" or
+// "fake"), defaulting to Gemini when GEMINI_API_KEY is set. Models and
+// timeouts are read the same way from LLM_<FEATURE>_MODEL / LLM_MODEL and
+// LLM_<FEATURE>_TIMEOUT / LLM_TIMEOUT, and LLM_<FEATURE>_CACHE=false keeps a
+// feature's responses out of the cache. Features sharing a provider share
+// its client.
+func Init() {
+	shared := map[string]Provider{}
+
+	for _, feature := range features {
+		name := setting(feature, "PROVIDER")
+		if name == "" && os.Getenv("GEMINI_API_KEY") != "" {
+			name = "gemini"
+		}
+		if name == "" {
+			log.Printf("[WARN] No LLM provider configured for %s", feature)
+			continue
+		}
+
+		provider, ok := shared[name]
+		if !ok {
+			var err error
+			provider, err = newProvider(name)
+			if err != nil {
+				log.Printf("[ERROR] Failed to initialise LLM provider %s: %v", name, err)
+				continue
+			}
+			shared[name] = provider
+		}
+
+		model := setting(feature, "MODEL")
+		if model == "" && feature == FeatureEmbeddings {
+			model = defaultEmbeddingModels[name]
+		} else if model == "" {
+			model = defaultModels[name]
+		}
+		timeout := defaultTimeout
+		if value := setting(feature, "TIMEOUT"); value != "" {
+			d, err := time.ParseDuration(value)
+			if err != nil {
+				log.Printf("[ERROR] Invalid LLM timeout %q for %s, using %s", value, feature, defaultTimeout)
+			} else {
+				timeout = d
+			}
+		}
+
+		cache := setting(feature, "CACHE") != "false"
+
+		routes[feature] = route{provider: provider, model: model, timeout: timeout, cache: cache}
+		log.Printf("[INFO] LLM feature %s using %s (%s)", feature, name, model)
+	}
+}
+
+func newProvider(name string) (Provider, error) {
+	switch name {
+	case "gemini":
+		return NewGemini(os.Getenv("GEMINI_API_KEY"))
+	case "openai":
+		return NewOpenAI(os.Getenv("OPENAI_BASE_URL"), os.Getenv("OPENAI_API_KEY")), nil
+	case "fake":
+		return NewFake(), nil
+	}
+	return nil, errors.New("llm: unknown provider " + name)
+}
+
+// setting reads LLM_<FEATURE>_<KEY>, falling back to LLM_<KEY>.
+func setting(feature, key string) string {
+	if value := os.Getenv("LLM_" + strings.ToUpper(feature) + "_" + key); value != "" {
+		return value
+	}
+	return os.Getenv("LLM_" + key)
+}
+
+// SetProvider routes a feature to p with the given model and timeout.
+func SetProvider(feature string, p Provider, model string, timeout time.Duration) {
+	routes[feature] = route{provider: p, model: model, timeout: timeout, cache: true}
+}
+
+// Generate runs req on the provider configured for feature, bounded by the
+// feature's timeout. An identical earlier request is answered from the cache
+// when one is set.
+func Generate(ctx context.Context, feature string, req Request) (Response, error) {
+	r, ok := routes[feature]
+	if !ok {
+		return Response{}, ErrNotConfigured
+	}
+	if req.Model == "" {
+		req.Model = r.model
+	}
+
+	key := ""
+	if cache != nil && r.cache {
+		key = cacheKey(feature, req)
+		if resp, ok := cache.Get(ctx, key); ok {
+			record(ctx, feature, req.Model, resp, true)
+			return resp, nil
+		}
+	}
+
+	if err := admitCall(ctx, feature); err != nil {
+		return Response{}, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	resp, err := r.provider.Generate(callCtx, req)
+	record(ctx, feature, req.Model, resp, false)
+	if err == nil && key != "" && cacheable(req, resp) {
+		cache.Set(ctx, key, resp)
+	}
+	return resp, err
+}
+
+// Stream runs req like Generate but calls onText with each piece of text as
+// it arrives. Providers that can't stream, and cached responses, deliver all
+// of it in one piece.
+func Stream(ctx context.Context, feature string, req Request, onText func(string) error) (Response, error) {
+	r, ok := routes[feature]
+	if !ok {
+		return Response{}, ErrNotConfigured
+	}
+	if req.Model == "" {
+		req.Model = r.model
+	}
+
+	key := ""
+	if cache != nil && r.cache {
+		key = cacheKey(feature, req)
+		if resp, ok := cache.Get(ctx, key); ok {
+			record(ctx, feature, req.Model, resp, true)
+			return resp, onText(resp.Text)
+		}
+	}
+
+	if err := admitCall(ctx, feature); err != nil {
+		return Response{}, err
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	var resp Response
+	var err error
+	if streamer, ok := r.provider.(Streamer); ok {
+		resp, err = streamer.Stream(callCtx, req, onText)
+	} else {
+		resp, err = r.provider.Generate(callCtx, req)
+		if err == nil {
+			err = onText(resp.Text)
+		}
+	}
+	record(ctx, feature, req.Model, resp, false)
+	if err == nil && key != "" && cacheable(req, resp) {
+		cache.Set(ctx, key, resp)
+	}
+	return resp, err
+}
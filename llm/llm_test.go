@@ -0,0 +1,73 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestInit(t *testing.T) {
+	t.Setenv("GEMINI_API_KEY", "")
+	t.Setenv("LLM_PROVIDER", "fake")
+	t.Setenv("LLM_SUGGESTIONS_MODEL", "custom")
+	t.Setenv("LLM_TIMEOUT", "2s")
+	defer func() { routes = map[string]route{} }()
+
+	Init()
+	r, ok := routes[FeatureSuggestions]
+	if !ok {
+		t.Fatal("suggestions not configured")
+	}
+	if _, ok := r.provider.(*Fake); !ok {
+		t.Errorf("provider = %T, want *Fake", r.provider)
+	}
+	if r.model != "custom" || r.timeout != 2*time.Second {
+		t.Errorf("model %q and timeout %s, want custom and 2s", r.model, r.timeout)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	fake := NewFake()
+	SetProvider("test", fake, "fake-model", time.Second)
+	defer delete(routes, "test")
+
+	resp, err := Generate(context.Background(), "test", Request{
+		System:   "Be brief",
+		Messages: []Message{{Role: RoleUser, Content: "see you  at\nnoon"}},
+	})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if want := "1. OK\n2. Thanks!\n3. Re: see you at noon"; resp.Text != want {
+		t.Errorf("text = %q, want %q", resp.Text, want)
+	}
+	if resp.Model != "fake-model" {
+		t.Errorf("model = %q, want the feature's model", resp.Model)
+	}
+	if resp.Usage.InputTokens != 6 || resp.Usage.OutputTokens != 10 {
+		t.Errorf("usage = %+v", resp.Usage)
+	}
+	if requests := fake.Requests(); len(requests) != 1 || requests[0].Model != "fake-model" {
+		t.Errorf("requests = %+v", requests)
+	}
+
+	fake.SetReply(func(req Request) string { return "custom " + req.System })
+	if resp, _ := Generate(context.Background(), "test", Request{System: "reply"}); resp.Text != "custom reply" {
+		t.Errorf("text with reply set = %q", resp.Text)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	if _, err := Generate(context.Background(), "missing", Request{}); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("unconfigured feature error = %v, want ErrNotConfigured", err)
+	}
+
+	SetProvider("test", NewFake(), "fake", time.Second)
+	defer delete(routes, "test")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Generate(ctx, "test", Request{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled call error = %v, want context.Canceled", err)
+	}
+}
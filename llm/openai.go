@@ -0,0 +1,289 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const defaultOpenAIBaseURL = "https://api.openai.com/v1"
+
+// OpenAI generates text through an OpenAI-compatible chat completions API.
+// Pointing the base URL at a local Ollama or llama.cpp server
+// (e.g. http://localhost:11434/v1) works the same way.
+type OpenAI struct {
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewOpenAI creates a provider for the API at baseURL, or OpenAI itself when
+// empty. The API key may be empty for local servers.
+func NewOpenAI(baseURL, apiKey string) *OpenAI {
+	if baseURL == "" {
+		baseURL = defaultOpenAIBaseURL
+	}
+	return &OpenAI{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		// Calls are bounded by their context, not the client
+		client: &http.Client{},
+	}
+}
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequest struct {
+	Model          string                `json:"model"`
+	Messages       []openAIMessage       `json:"messages"`
+	MaxTokens      int                   `json:"max_tokens,omitempty"`
+	Temperature    *float32              `json:"temperature,omitempty"`
+	Stream         bool                  `json:"stream,omitempty"`
+	StreamOptions  *openAIStreamOptions  `json:"stream_options,omitempty"`
+	ResponseFormat *openAIResponseFormat `json:"response_format,omitempty"`
+}
+
+type openAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *openAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+type openAIJSONSchema struct {
+	Name   string  `json:"name"`
+	Schema *Schema `json:"schema"`
+	Strict bool    `json:"strict"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+type openAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+type openAIResponse struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Message openAIMessage `json:"message"`
+	} `json:"choices"`
+	Usage openAIUsage `json:"usage"`
+}
+
+// openAIChunk is one server-sent event of a streamed response.
+type openAIChunk struct {
+	Model   string `json:"model"`
+	Choices []struct {
+		Delta struct {
+			Content string `json:"content"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAIUsage `json:"usage"`
+}
+
+type openAIEmbeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type openAIEmbeddingResponse struct {
+	Model string `json:"model"`
+	Data  []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Usage openAIUsage `json:"usage"`
+}
+
+type openAIError struct {
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (o *OpenAI) Generate(ctx context.Context, req Request) (Response, error) {
+	resp, err := o.do(ctx, req, false)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	var result openAIResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Response{}, fmt.Errorf("llm: decoding response: %w", err)
+	}
+	if len(result.Choices) == 0 {
+		return Response{}, fmt.Errorf("llm: response has no choices")
+	}
+
+	model := result.Model
+	if model == "" {
+		model = req.Model
+	}
+	return Response{
+		Text:  result.Choices[0].Message.Content,
+		Model: model,
+		Usage: Usage{
+			InputTokens:  result.Usage.PromptTokens,
+			OutputTokens: result.Usage.CompletionTokens,
+		},
+	}, nil
+}
+
+func (o *OpenAI) Stream(ctx context.Context, req Request, onText func(string) error) (Response, error) {
+	resp, err := o.do(ctx, req, true)
+	if err != nil {
+		return Response{}, err
+	}
+	defer resp.Body.Close()
+
+	result := Response{Model: req.Model}
+	var text strings.Builder
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+		if data == "[DONE]" {
+			break
+		}
+
+		var chunk openAIChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return Response{}, fmt.Errorf("llm: decoding stream: %w", err)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			result.Usage = Usage{
+				InputTokens:  chunk.Usage.PromptTokens,
+				OutputTokens: chunk.Usage.CompletionTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		piece := chunk.Choices[0].Delta.Content
+		text.WriteString(piece)
+		if err := onText(piece); err != nil {
+			return Response{}, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Response{}, err
+	}
+
+	result.Text = text.String()
+	return result, nil
+}
+
+func (o *OpenAI) Embed(ctx context.Context, req EmbedRequest) (EmbedResponse, error) {
+	resp, err := o.post(ctx, "/embeddings", openAIEmbeddingRequest{Model: req.Model, Input: req.Texts})
+	if err != nil {
+		return EmbedResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	var result openAIEmbeddingResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return EmbedResponse{}, fmt.Errorf("llm: decoding response: %w", err)
+	}
+	if len(result.Data) != len(req.Texts) {
+		return EmbedResponse{}, fmt.Errorf("llm: got %d embeddings for %d texts", len(result.Data), len(req.Texts))
+	}
+
+	vectors := make([][]float32, len(req.Texts))
+	for _, item := range result.Data {
+		if item.Index < 0 || item.Index >= len(vectors) {
+			return EmbedResponse{}, fmt.Errorf("llm: embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	model := result.Model
+	if model == "" {
+		model = req.Model
+	}
+	return EmbedResponse{
+		Vectors: vectors,
+		Model:   model,
+		Usage:   Usage{InputTokens: result.Usage.PromptTokens},
+	}, nil
+}
+
+// do sends a chat completion request and returns the response if it
+// succeeded.
+func (o *OpenAI) do(ctx context.Context, req Request, stream bool) (*http.Response, error) {
+	messages := make([]openAIMessage, 0, len(req.Messages)+1)
+	if req.System != "" {
+		messages = append(messages, openAIMessage{Role: "system", Content: req.System})
+	}
+	for _, msg := range req.Messages {
+		messages = append(messages, openAIMessage{Role: msg.Role, Content: msg.Content})
+	}
+
+	body := openAIRequest{
+		Model:       req.Model,
+		Messages:    messages,
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	if req.Schema != nil {
+		body.ResponseFormat = &openAIResponseFormat{
+			Type:       "json_schema",
+			JSONSchema: &openAIJSONSchema{Name: "response", Schema: req.Schema, Strict: true},
+		}
+	}
+	if stream {
+		body.Stream = true
+		body.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+	}
+	return o.post(ctx, "/chat/completions", body)
+}
+
+// post sends body as JSON to the API path and returns the response if it
+// succeeded.
+func (o *OpenAI) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	message := http.StatusText(resp.StatusCode)
+	respBody, _ := io.ReadAll(resp.Body)
+	var apiErr openAIError
+	if json.Unmarshal(respBody, &apiErr) == nil && apiErr.Error != nil {
+		message = apiErr.Error.Message
+	}
+	return nil, fmt.Errorf("llm: API returned %d: %s", resp.StatusCode, message)
+}
@@ -0,0 +1,194 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Schema is the subset of JSON Schema that every provider can enforce.
+type Schema struct {
+	Type        string             `json:"type"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []string           `json:"enum,omitempty"`
+	MinItems    *int               `json:"minItems,omitempty"`
+	MaxItems    *int               `json:"maxItems,omitempty"`
+	// AdditionalProperties must be false on objects for strict providers
+	AdditionalProperties *bool `json:"additionalProperties,omitempty"`
+}
+
+// Schema types
+const (
+	TypeObject  = "object"
+	TypeArray   = "array"
+	TypeString  = "string"
+	TypeNumber  = "number"
+	TypeInteger = "integer"
+	TypeBoolean = "boolean"
+)
+
+// jsonAttempts is how many times GenerateJSON asks before giving up.
+const jsonAttempts = 3
+
+// ErrInvalidOutput is returned when the model never produced output
+// matching the schema.
+var ErrInvalidOutput = errors.New("llm: model output does not match the schema")
+
+// GenerateJSON runs req with req.Schema enforced and decodes the result into
+// out. Output that isn't valid JSON or doesn't match the schema is sent back
+// to the model with the problem, up to three attempts in all. The returned
+// usage covers every attempt.
+func GenerateJSON(ctx context.Context, feature string, req Request, out interface{}) (Response, error) {
+	if req.Schema == nil {
+		return Response{}, errors.New("llm: GenerateJSON needs a schema")
+	}
+
+	var usage Usage
+	for attempt := 1; ; attempt++ {
+		resp, err := Generate(ctx, feature, req)
+		usage.InputTokens += resp.Usage.InputTokens
+		usage.OutputTokens += resp.Usage.OutputTokens
+		resp.Usage = usage
+		if err != nil {
+			return resp, err
+		}
+
+		problem := DecodeJSON(resp.Text, req.Schema, out)
+		if problem == nil {
+			return resp, nil
+		}
+		if attempt == jsonAttempts {
+			return resp, fmt.Errorf("%w: %v", ErrInvalidOutput, problem)
+		}
+
+		req.Messages = append(req.Messages,
+			Message{Role: RoleAssistant, Content: resp.Text},
+			Message{Role: RoleUser, Content: "That reply is invalid: " + problem.Error() +
+				". Answer again with only JSON matching the schema."},
+		)
+	}
+}
+
+// DecodeJSON checks text against schema and decodes it into out.
+func DecodeJSON(text string, schema *Schema, out interface{}) error {
+	text = stripCodeFence(text)
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return fmt.Errorf("not valid JSON: %v", err)
+	}
+	if err := schema.Validate(value); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(text), out)
+}
+
+// Validate reports the first way value, as decoded by encoding/json, breaks
+// the schema.
+func (s *Schema) Validate(value interface{}) error {
+	return s.validate("$", value)
+}
+
+func (s *Schema) validate(path string, value interface{}) error {
+	switch s.Type {
+	case TypeObject:
+		obj, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an object", path)
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		for name, v := range obj {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s is not allowed", path, name)
+				}
+				continue
+			}
+			if err := prop.validate(path+"."+name, v); err != nil {
+				return err
+			}
+		}
+	case TypeArray:
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s must be an array", path)
+		}
+		if s.MinItems != nil && len(items) < *s.MinItems {
+			return fmt.Errorf("%s must have at least %d items", path, *s.MinItems)
+		}
+		if s.MaxItems != nil && len(items) > *s.MaxItems {
+			return fmt.Errorf("%s must have at most %d items", path, *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range items {
+				if err := s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+					return err
+				}
+			}
+		}
+	case TypeString:
+		str, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", path)
+		}
+		if len(s.Enum) > 0 && !contains(s.Enum, str) {
+			return fmt.Errorf("%s must be one of %s", path, strings.Join(s.Enum, ", "))
+		}
+	case TypeNumber, TypeInteger:
+		n, ok := value.(float64)
+		if !ok || (s.Type == TypeInteger && n != float64(int64(n))) {
+			return fmt.Errorf("%s must be a %s", path, s.Type)
+		}
+	case TypeBoolean:
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", path)
+		}
+	}
+	return nil
+}
+
+// propertyNames returns the object's properties, required ones first in
+// their listed order, so output has a stable shape.
+func (s *Schema) propertyNames() []string {
+	names := append([]string(nil), s.Required...)
+	var rest []string
+	for name := range s.Properties {
+		if !contains(names, name) {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// stripCodeFence removes a ```json fence some models wrap JSON in.
+func stripCodeFence(text string) string {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "```") {
+		return text
+	}
+	text = strings.TrimPrefix(text, "```")
+	text = strings.TrimPrefix(text, "json")
+	text = strings.TrimSuffix(strings.TrimSpace(text), "```")
+	return strings.TrimSpace(text)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSchema() *Schema {
+	closed := false
+	two := 2
+	return &Schema{
+		Type: TypeObject,
+		Properties: map[string]*Schema{
+			"language": {Type: TypeString, Enum: []string{"en", "de"}},
+			"items": {
+				Type:     TypeArray,
+				Items:    &Schema{Type: TypeString},
+				MinItems: &two,
+				MaxItems: &two,
+			},
+			"count": {Type: TypeInteger},
+			"done":  {Type: TypeBoolean},
+		},
+		Required:             []string{"language", "items"},
+		AdditionalProperties: &closed,
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		wantErr string
+	}{
+		{"valid", `{"language": "en", "items": ["a", "b"]}`, ""},
+		{"all properties", `{"language": "de", "items": ["a", "b"], "count": 3, "done": true}`, ""},
+		{"code fence", "```json\n{\"language\": \"en\", \"items\": [\"a\", \"b\"]}\n```", ""},
+		{"not JSON", `language: en`, "not valid JSON"},
+		{"not an object", `["en"]`, "$ must be an object"},
+		{"missing required", `{"items": ["a", "b"]}`, "$.language is required"},
+		{"extra property", `{"language": "en", "items": ["a", "b"], "mood": "happy"}`, "$.mood is not allowed"},
+		{"not in enum", `{"language": "fr", "items": ["a", "b"]}`, "$.language must be one of en, de"},
+		{"too few items", `{"language": "en", "items": ["a"]}`, "$.items must have at least 2 items"},
+		{"too many items", `{"language": "en", "items": ["a", "b", "c"]}`, "$.items must have at most 2 items"},
+		{"wrong item type", `{"language": "en", "items": ["a", 2]}`, "$.items[1] must be a string"},
+		{"fractional integer", `{"language": "en", "items": ["a", "b"], "count": 1.5}`, "$.count must be a integer"},
+		{"wrong boolean", `{"language": "en", "items": ["a", "b"], "done": "yes"}`, "$.done must be a boolean"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out struct {
+				Language string   `json:"language"`
+				Items    []string `json:"items"`
+			}
+			err := DecodeJSON(tt.text, testSchema(), &out)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("DecodeJSON() error = %v", err)
+				}
+				if out.Language == "" || len(out.Items) != 2 {
+					t.Errorf("DecodeJSON() decoded %+v", out)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("DecodeJSON() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFakeFollowsSchema(t *testing.T) {
+	resp, err := NewFake().Generate(context.Background(), Request{Schema: testSchema()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value interface{}
+	if err := DecodeJSON(resp.Text, testSchema(), &value); err != nil {
+		t.Errorf("fake output %s doesn't match the schema: %v", resp.Text, err)
+	}
+}
+
+func TestGenerateJSONRetries(t *testing.T) {
+	fake := NewFake()
+	SetProvider("test", fake, "fake", time.Second)
+	defer delete(routes, "test")
+
+	replies := []string{`not json`, `{"language": "en", "items": ["a"]}`, `{"language": "en", "items": ["a", "b"]}`}
+	fake.SetReply(func(req Request) string {
+		return replies[len(fake.Requests())-1]
+	})
+
+	var out struct {
+		Items []string `json:"items"`
+	}
+	resp, err := GenerateJSON(context.Background(), "test", Request{Schema: testSchema()}, &out)
+	if err != nil {
+		t.Fatalf("GenerateJSON() error = %v", err)
+	}
+	if len(out.Items) != 2 {
+		t.Errorf("GenerateJSON() decoded %+v", out)
+	}
+
+	requests := fake.Requests()
+	if len(requests) != 3 {
+		t.Fatalf("made %d requests, want 3", len(requests))
+	}
+	// Each retry tells the model what was wrong with its last answer
+	last := requests[2].Messages
+	if len(last) != 4 || !strings.Contains(last[3].Content, "$.items must have at least 2 items") {
+		t.Errorf("last retry messages = %+v", last)
+	}
+	if resp.Usage.OutputTokens == 0 {
+		t.Errorf("usage isn't summed over attempts: %+v", resp.Usage)
+	}
+}
+
+func TestGenerateJSONGivesUp(t *testing.T) {
+	fake := NewFake()
+	fake.SetReply(func(Request) string { return "{}" })
+	SetProvider("test", fake, "fake", time.Second)
+	defer delete(routes, "test")
+
+	var out interface{}
+	_, err := GenerateJSON(context.Background(), "test", Request{Schema: testSchema()}, &out)
+	if !errors.Is(err, ErrInvalidOutput) {
+		t.Errorf("GenerateJSON() error = %v, want ErrInvalidOutput", err)
+	}
+	if n := len(fake.Requests()); n != jsonAttempts {
+		t.Errorf("made %d requests, want %d", n, jsonAttempts)
+	}
+}
@@ -0,0 +1,111 @@
+package llm
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Call describes one generation for accounting. Cached calls reached no
+// provider and used no tokens.
+type Call struct {
+	// User is who the call was made for, as set with WithUser
+	User    string
+	Feature string
+	Model   string
+	Usage   Usage
+	Cached  bool
+}
+
+// Cache stores responses by a key derived from the whole request.
+type Cache interface {
+	Get(ctx context.Context, key string) (Response, bool)
+	Set(ctx context.Context, key string, resp Response)
+}
+
+// SystemUser is who calls the service makes for itself, rather than for
+// any one user, are attributed to.
+const SystemUser = "system"
+
+type userKey struct{}
+
+var (
+	cache       Cache
+	usageRecord func(ctx context.Context, call Call)
+	admit       func(ctx context.Context, user, feature string) error
+)
+
+// WithUser attributes calls made with the returned context to a user.
+func WithUser(ctx context.Context, userID string) context.Context {
+	return context.WithValue(ctx, userKey{}, userID)
+}
+
+// SetCache answers identical requests from c. Nil disables caching.
+func SetCache(c Cache) {
+	cache = c
+}
+
+// SetUsageRecorder has fn called after every generation, whether it
+// succeeded or not. fn must not rely on ctx still being live.
+func SetUsageRecorder(fn func(ctx context.Context, call Call)) {
+	usageRecord = fn
+}
+
+// SetAdmission has fn called before every call that will reach a provider,
+// so cached responses aren't counted. An error from fn is returned instead
+// of making the call.
+func SetAdmission(fn func(ctx context.Context, user, feature string) error) {
+	admit = fn
+}
+
+// admitCall asks the admission hook whether the call may be made.
+func admitCall(ctx context.Context, feature string) error {
+	if admit == nil {
+		return nil
+	}
+	user, _ := ctx.Value(userKey{}).(string)
+	return admit(ctx, user, feature)
+}
+
+func record(ctx context.Context, feature, requested string, resp Response, cached bool) {
+	if usageRecord == nil {
+		return
+	}
+	user, _ := ctx.Value(userKey{}).(string)
+	// Failed calls have no response to name the model
+	model := resp.Model
+	if model == "" {
+		model = requested
+	}
+	usageRecord(ctx, Call{
+		User:    user,
+		Feature: feature,
+		Model:   model,
+		Usage:   resp.Usage,
+		Cached:  cached,
+	})
+}
+
+// cacheKey hashes everything that affects a response.
+func cacheKey(feature string, req Request) string {
+	data, _ := json.Marshal(struct {
+		Feature string
+		Request Request
+	}{feature, req})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// cacheable keeps empty output, and output that breaks the request's schema,
+// out of the cache.
+func cacheable(req Request, resp Response) bool {
+	if resp.Text == "" {
+		return false
+	}
+	if req.Schema == nil {
+		return true
+	}
+	var value interface{}
+	return DecodeJSON(resp.Text, req.Schema, &value) == nil
+}
@@ -0,0 +1,65 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// memoryCache is a Cache kept in a map.
+type memoryCache map[string]Response
+
+func (m memoryCache) Get(ctx context.Context, key string) (Response, bool) {
+	resp, ok := m[key]
+	return resp, ok
+}
+
+func (m memoryCache) Set(ctx context.Context, key string, resp Response) {
+	m[key] = resp
+}
+
+func TestAdmissionOnlyOnCacheMiss(t *testing.T) {
+	fake := NewFake()
+	SetProvider("test", fake, "fake", time.Second)
+	SetCache(memoryCache{})
+	var admitted []string
+	var refuse error
+	SetAdmission(func(ctx context.Context, user, feature string) error {
+		admitted = append(admitted, user+" "+feature)
+		return refuse
+	})
+	var calls []Call
+	SetUsageRecorder(func(ctx context.Context, call Call) {
+		calls = append(calls, call)
+	})
+	defer func() {
+		delete(routes, "test")
+		SetCache(nil)
+		SetAdmission(nil)
+		SetUsageRecorder(nil)
+	}()
+
+	ctx := WithUser(context.Background(), "alice")
+	req := Request{Messages: []Message{{Role: RoleUser, Content: "hello"}}}
+	for i := 0; i < 2; i++ {
+		if _, err := Generate(ctx, "test", req); err != nil {
+			t.Fatalf("Generate() error = %v", err)
+		}
+	}
+	if len(admitted) != 1 || admitted[0] != "alice test" {
+		t.Errorf("admitted %v, want one call for alice", admitted)
+	}
+	if len(calls) != 2 || calls[0].Cached || !calls[1].Cached {
+		t.Errorf("recorded %+v, want a provider call then a cached one", calls)
+	}
+
+	refuse = errors.New("over quota")
+	req.Messages[0].Content = "something new"
+	if _, err := Generate(ctx, "test", req); err != refuse {
+		t.Errorf("Generate() error = %v, want the admission error", err)
+	}
+	if n := len(fake.Requests()); n != 1 {
+		t.Errorf("provider got %d requests, want 1", n)
+	}
+}
@@ -0,0 +1,82 @@
+// Package mailer renders and sends transactional email.
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	htmltemplate "html/template"
+	"log"
+	"os"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+)
+
+// Email is a message with both plain text and HTML bodies.
+type Email struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers email.
+type Mailer interface {
+	Send(ctx context.Context, email Email) error
+}
+
+// Default is the mailer used by the application, set up by Init.
+var Default Mailer = LogMailer{}
+
+// Init configures SMTP delivery from the environment. Without SMTP_HOST,
+// emails are only logged.
+func Init() {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		log.Println("[INFO] SMTP_HOST not set, emails will be logged instead of sent")
+		return
+	}
+
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "587"
+	}
+
+	Default = &SMTPMailer{
+		Host:     host,
+		Port:     port,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("MAIL_FROM"),
+	}
+	log.Println("[INFO] SMTP mailer initialized")
+}
+
+// Render builds the text and HTML bodies of the named template pair, e.g.
+// "digest" renders templates/digest.txt and templates/digest.html.
+func Render(name string, data interface{}) (text, html string, err error) {
+	var textBuf, htmlBuf bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&textBuf, name+".txt", data); err != nil {
+		return "", "", err
+	}
+	if err := htmlTemplates.ExecuteTemplate(&htmlBuf, name+".html", data); err != nil {
+		return "", "", err
+	}
+	return textBuf.String(), htmlBuf.String(), nil
+}
+
+// LogMailer writes emails to the log instead of sending them, for
+// development.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, email Email) error {
+	log.Printf("[INFO] Email to %s: %s\n%s", email.To, email.Subject, email.Text)
+	return nil
+}
@@ -1,6 +1,10 @@
 package main
 
 import (
+	"github.com/sajanIocod/chat_backend/controllers"
+	"github.com/sajanIocod/chat_backend/llm"
+	"github.com/sajanIocod/chat_backend/mailer"
+	"github.com/sajanIocod/chat_backend/push"
 	"github.com/sajanIocod/chat_backend/routes"
 	"github.com/sajanIocod/chat_backend/utils"
 )
@@ -8,6 +12,15 @@ import (
 func main() {
 	utils.ConnectDB()
 	utils.InitPusher()
+	push.Init()
+	mailer.Init()
+	llm.Init()
+	controllers.InitAIAccounting()
+	controllers.EnsureBots()
+	controllers.StartPresenceSweeper()
+	controllers.StartDigestJob()
+	controllers.StartAccountDeletionJob()
+	controllers.StartEmbeddingJob()
 	r := routes.SetupRouter()
 	r.Run(":8080")
 }
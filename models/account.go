@@ -0,0 +1,15 @@
+package models
+
+type DeleteAccountRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+}
+
+// What happens to a deleted user's messages, chosen by
+// ACCOUNT_MESSAGE_POLICY
+const (
+	// MessagePolicyAnonymise keeps the messages in the other participants'
+	// histories but ties them to a fresh ID instead of the deleted account
+	MessagePolicyAnonymise = "anonymise"
+	// MessagePolicyDelete removes every message the user sent or received
+	MessagePolicyDelete = "delete"
+)
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// AIUsage is one user's use of one model for one feature on one UTC day.
+type AIUsage struct {
+	// UserID is the user's ObjectID, or "system" for calls the service
+	// makes for itself
+	UserID  interface{} `json:"userId" bson:"userId"`
+	Feature string      `json:"feature" bson:"feature"`
+	Model   string      `json:"model" bson:"model"`
+	// Day is "2006-01-02" and Month "2006-01"
+	Day   string `json:"day" bson:"day"`
+	Month string `json:"month" bson:"month"`
+	// Requests counts provider calls; answers from the cache are counted
+	// apart and use no tokens
+	Requests       int64     `json:"requests" bson:"requests"`
+	CachedRequests int64     `json:"cachedRequests" bson:"cachedRequests"`
+	InputTokens    int64     `json:"inputTokens" bson:"inputTokens"`
+	OutputTokens   int64     `json:"outputTokens" bson:"outputTokens"`
+	CostUSD        float64   `json:"costUsd" bson:"costUsd"`
+	UpdatedAt      time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// AICacheEntry is a stored model response for an identical request.
+type AICacheEntry struct {
+	Key          string    `bson:"key"`
+	Text         string    `bson:"text"`
+	Model        string    `bson:"model"`
+	InputTokens  int       `bson:"inputTokens"`
+	OutputTokens int       `bson:"outputTokens"`
+	ExpiresAt    time.Time `bson:"expiresAt"`
+}
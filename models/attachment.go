@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Attachment is an uploaded file stored under the upload directory.
+type Attachment struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	OwnerID     primitive.ObjectID `json:"ownerId" bson:"ownerId"`
+	Filename    string             `json:"filename" bson:"filename"`
+	ContentType string             `json:"contentType" bson:"contentType"`
+	Size        int64              `json:"size" bson:"size"`
+	// Path is relative to the upload directory
+	Path      string    `json:"-" bson:"path"`
+	URL       string    `json:"url" bson:"url"`
+	CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
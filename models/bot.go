@@ -0,0 +1,22 @@
+package models
+
+// BotConfig is how an assistant bot behaves.
+type BotConfig struct {
+	SystemPrompt string `bson:"systemPrompt"`
+	// ContextMessages is how many recent messages of a conversation the bot
+	// sees when replying
+	ContextMessages int `bson:"contextMessages"`
+	// RepliesPerMinute caps how often the bot answers one user
+	RepliesPerMinute int `bson:"repliesPerMinute"`
+}
+
+// BotDefinition is one entry of the BOTS_FILE the bots are created from.
+type BotDefinition struct {
+	Username         string `json:"username"`
+	DisplayName      string `json:"displayName"`
+	Bio              string `json:"bio"`
+	AvatarURL        string `json:"avatarUrl"`
+	SystemPrompt     string `json:"systemPrompt"`
+	ContextMessages  int    `json:"contextMessages"`
+	RepliesPerMinute int    `json:"repliesPerMinute"`
+}
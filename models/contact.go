@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Contact request states
+const (
+	ContactStatusPending  = "pending"
+	ContactStatusAccepted = "accepted"
+	ContactStatusDeclined = "declined"
+)
+
+// Who may start a conversation with a user
+const (
+	MessagePermissionEveryone = "everyone"
+	MessagePermissionContacts = "contacts"
+)
+
+// Contact links two users. There is one per pair, keyed by their
+// conversation key; RequesterID is whoever asked first.
+type Contact struct {
+	Key         string             `json:"-" bson:"key"`
+	RequesterID primitive.ObjectID `json:"requesterId" bson:"requesterId"`
+	AddresseeID primitive.ObjectID `json:"addresseeId" bson:"addresseeId"`
+	Status      string             `json:"status" bson:"status"`
+	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	RespondedAt *time.Time         `json:"respondedAt,omitempty" bson:"respondedAt,omitempty"`
+}
+
+type ContactSettingsRequest struct {
+	MessagePermission string `json:"messagePermission" binding:"required,oneof=everyone contacts"`
+}
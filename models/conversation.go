@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ConversationSettings is one user's personal state for a conversation,
+// identified by the other participant.
+type ConversationSettings struct {
+	UserID     primitive.ObjectID `json:"userId" bson:"userId"`
+	PeerID     primitive.ObjectID `json:"peerId" bson:"peerId"`
+	MutedUntil *time.Time         `json:"mutedUntil" bson:"mutedUntil"`
+	Archived   bool               `json:"archived" bson:"archived"`
+	Pinned     bool               `json:"pinned" bson:"pinned"`
+	// PinOrder sorts pinned conversations, lowest first
+	PinOrder int `json:"pinOrder" bson:"pinOrder"`
+	// AutoTranslate is the language code messages from the peer are
+	// translated into when fetched, or empty when off
+	AutoTranslate string    `json:"autoTranslate" bson:"autoTranslate,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// ConversationSettingsRequest changes only the fields that are present.
+// Unmute with a mutedUntil in the past and turn auto-translate off with an
+// empty autoTranslate.
+type ConversationSettingsRequest struct {
+	MutedUntil    *time.Time `json:"mutedUntil"`
+	Archived      *bool      `json:"archived"`
+	Pinned        *bool      `json:"pinned"`
+	PinOrder      *int       `json:"pinOrder"`
+	AutoTranslate *string    `json:"autoTranslate"`
+}
@@ -6,16 +6,59 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Message delivery states, in the order a message moves through them
+const (
+	MessageStatusSent      = "sent"
+	MessageStatusDelivered = "delivered"
+	MessageStatusRead      = "read"
+)
+
 type Message struct {
-	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	SenderID   primitive.ObjectID `json:"senderID" bson:"senderID"`
-	ReceiverID primitive.ObjectID `json:"receiverID" bson:"receiverID"`
-	Content    string             `json:"content" bson:"content"`
-	Seen       bool               `json:"seen" bson:"seen"`
-	CreatedAt  time.Time          `json:"createdAt" bson:"createdAt"`
+	ID primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	// ClientMessageID is generated by the sending client and makes retries
+	// idempotent per sender
+	ClientMessageID string             `json:"clientMessageId,omitempty" bson:"clientMessageId,omitempty"`
+	SenderID        primitive.ObjectID `json:"senderID" bson:"senderID"`
+	ReceiverID      primitive.ObjectID `json:"receiverID" bson:"receiverID"`
+	Content         string             `json:"content" bson:"content"`
+	// Attachments are files the sender uploaded and sent with the message
+	Attachments []Attachment `json:"attachments,omitempty" bson:"attachments,omitempty"`
+	// Seen mirrors Status == read and is kept for unread counts
+	Seen        bool       `json:"seen" bson:"seen"`
+	Status      string     `json:"status" bson:"status"`
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty" bson:"deliveredAt,omitempty"`
+	ReadAt      *time.Time `json:"readAt,omitempty" bson:"readAt,omitempty"`
+	// DigestedAt is set once the message was included in an unread digest
+	DigestedAt *time.Time `json:"-" bson:"digestedAt,omitempty"`
+	// EmbeddedAt is set once the message is in the semantic search index
+	EmbeddedAt *time.Time `json:"-" bson:"embeddedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+	// Translation is attached to fetched messages when the reader has
+	// auto-translate on; it isn't stored with the message
+	Translation *MessageTranslation `json:"translation,omitempty" bson:"-"`
 }
 
+// MessageRequest is a message to send. It needs content, attachments or
+// both.
 type MessageRequest struct {
-	ReceiverID string `json:"receiverId" binding:"required"`
-	Content    string `json:"content" binding:"required"`
+	ReceiverID      string   `json:"receiverId" binding:"required"`
+	Content         string   `json:"content"`
+	AttachmentIDs   []string `json:"attachmentIds" binding:"max=10"`
+	ClientMessageID string   `json:"clientMessageId"`
+}
+
+type TypingRequest struct {
+	Typing bool `json:"typing"`
+}
+
+type AckRequest struct {
+	MessageIDs []string `json:"messageIds" binding:"required"`
+}
+
+// ReadPointer records the last message a member of a conversation has read.
+type ReadPointer struct {
+	ConversationKey   string             `json:"conversationKey" bson:"conversationKey"`
+	UserID            primitive.ObjectID `json:"userId" bson:"userId"`
+	LastReadMessageID primitive.ObjectID `json:"lastReadMessageId" bson:"lastReadMessageId"`
+	LastReadAt        time.Time          `json:"lastReadAt" bson:"lastReadAt"`
 }
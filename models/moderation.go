@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Block stops BlockedID from messaging or finding BlockerID.
+type Block struct {
+	BlockerID primitive.ObjectID `json:"blockerId" bson:"blockerId"`
+	BlockedID primitive.ObjectID `json:"blockedId" bson:"blockedId"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+// Report states, as a moderator works through the queue
+const (
+	ReportStatusOpen     = "open"
+	ReportStatusResolved = "resolved"
+)
+
+// Report is an entry in the moderation queue. Messages is a copy taken at
+// report time, so it survives later deletion of the originals.
+type Report struct {
+	ID             primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	ReporterID     primitive.ObjectID `json:"reporterId" bson:"reporterId"`
+	ReportedUserID primitive.ObjectID `json:"reportedUserId" bson:"reportedUserId"`
+	Reason         string             `json:"reason" bson:"reason"`
+	Messages       []Message          `json:"messages" bson:"messages"`
+	Status         string             `json:"status" bson:"status"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+}
+
+type ReportRequest struct {
+	Reason string `json:"reason" binding:"required"`
+	// MessageIDs picks the offending messages; without it the most recent
+	// messages from the reported user are attached
+	MessageIDs []string `json:"messageIds"`
+	// Block also blocks the reported user
+	Block bool `json:"block"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Presence tracks whether a user currently has a realtime connection open.
+// Channels holds every channel the user is subscribed to; the user is online
+// while it is non-empty or while heartbeats from a polling client keep
+// arriving.
+type Presence struct {
+	UserID      primitive.ObjectID `json:"userId" bson:"_id"`
+	Online      bool               `json:"online" bson:"online"`
+	Channels    []string           `json:"-" bson:"channels"`
+	HeartbeatAt time.Time          `json:"-" bson:"heartbeatAt"`
+	LastSeenAt  time.Time          `json:"lastSeenAt" bson:"lastSeenAt"`
+}
+
+type PresenceSettingsRequest struct {
+	HideLastSeen bool `json:"hideLastSeen"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "go.mongodb.org/mongo-driver/bson/primitive"
+
+// SearchResult is a message found by a search, with the conversation it is
+// in. Peer is nil when the other participant's account was deleted.
+type SearchResult struct {
+	Message        Message            `json:"message"`
+	ConversationID primitive.ObjectID `json:"conversationId"`
+	Peer           *PublicProfile     `json:"peer"`
+	Score          float64            `json:"score"`
+	// Snippet is the part of the message around the matched words, HTML
+	// escaped, with each match wrapped in <mark>; only set by full-text
+	// search
+	Snippet string `json:"snippet,omitempty"`
+	// Context holds the messages around the result, oldest first
+	Context *SearchContext `json:"context,omitempty"`
+}
+
+type SearchContext struct {
+	Before []Message `json:"before"`
+	After  []Message `json:"after"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Session is one login. Tokens stop working as soon as their session is
+// deleted.
+type Session struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id"`
+	UserID    primitive.ObjectID `json:"userId" bson:"userId"`
+	CreatedAt time.Time          `json:"createdAt" bson:"createdAt"`
+	ExpiresAt time.Time          `json:"expiresAt" bson:"expiresAt"`
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+	NewPassword     string `json:"newPassword" binding:"required,min=8"`
+}
+
+type ChangeEmailRequest struct {
+	NewEmail        string `json:"newEmail" binding:"required,email"`
+	CurrentPassword string `json:"currentPassword" binding:"required"`
+}
+
+// Email change states
+const (
+	EmailChangePending  = "pending"
+	EmailChangeVerified = "verified"
+	EmailChangeReverted = "reverted"
+)
+
+// EmailChange tracks a request to move an account to a new address. Only
+// hashes of the verify and revert tokens are stored.
+type EmailChange struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	UserID          primitive.ObjectID `bson:"userId"`
+	OldEmail        string             `bson:"oldEmail"`
+	NewEmail        string             `bson:"newEmail"`
+	VerifyTokenHash string             `bson:"verifyTokenHash"`
+	RevertTokenHash string             `bson:"revertTokenHash"`
+	Status          string             `bson:"status"`
+	CreatedAt       time.Time          `bson:"createdAt"`
+	VerifyExpiresAt time.Time          `bson:"verifyExpiresAt"`
+	RevertExpiresAt time.Time          `bson:"revertExpiresAt"`
+	CompletedAt     *time.Time         `bson:"completedAt,omitempty"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// Suggestion tones
+const (
+	ToneCasual     = "casual"
+	ToneFormal     = "formal"
+	ToneBrief      = "brief"
+	ToneEmpathetic = "empathetic"
+)
+
+// SuggestionRequest asks for reply suggestions in a conversation. The
+// conversation is identified by the other member's user ID. Count defaults
+// to 3 and Tone to casual.
+type SuggestionRequest struct {
+	ConversationID string `json:"conversationId" binding:"required"`
+	Count          int    `json:"count" binding:"omitempty,min=1,max=5"`
+	Tone           string `json:"tone" binding:"omitempty,oneof=casual formal brief empathetic"`
+}
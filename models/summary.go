@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SummaryRequest picks the messages to summarise: those between From and
+// To when either is set, otherwise everything since the oldest unread
+// message.
+type SummaryRequest struct {
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+}
+
+// ActionItem is something a participant said they or someone else would do.
+type ActionItem struct {
+	Text string `json:"text" bson:"text"`
+	// Assignee is who should do it, empty when nobody was named
+	Assignee string `json:"assignee" bson:"assignee"`
+}
+
+// ConversationSummary is a generated summary of a run of messages, cached
+// per reader under the IDs of its first and last message.
+type ConversationSummary struct {
+	Key            string             `json:"-" bson:"key"`
+	UserID         primitive.ObjectID `json:"-" bson:"userId"`
+	FirstMessageID primitive.ObjectID `json:"firstMessageId" bson:"firstMessageId"`
+	LastMessageID  primitive.ObjectID `json:"lastMessageId" bson:"lastMessageId"`
+	MessageCount   int                `json:"messageCount" bson:"messageCount"`
+	Summary        string             `json:"summary" bson:"summary"`
+	Highlights     []string           `json:"highlights" bson:"highlights"`
+	ActionItems    []ActionItem       `json:"actionItems" bson:"actionItems"`
+	CreatedAt      time.Time          `json:"createdAt" bson:"createdAt"`
+}
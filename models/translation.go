@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MessageTranslation is a message's content in another language, stored
+// once per message and language.
+type MessageTranslation struct {
+	MessageID primitive.ObjectID `json:"messageId" bson:"messageId"`
+	// Language is the ISO 639-1 code translated into, and SourceLanguage the
+	// one the message was written in
+	Language       string    `json:"language" bson:"language"`
+	SourceLanguage string    `json:"sourceLanguage" bson:"sourceLanguage"`
+	Text           string    `json:"text" bson:"text"`
+	CreatedAt      time.Time `json:"createdAt" bson:"createdAt"`
+}
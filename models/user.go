@@ -7,10 +7,37 @@ import (
 )
 
 type User struct {
-	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
-	Username string             `bson:"username"`
-	Email    string             `json:"email" bson:"email"`
-	Password string             `json:"password" bson:"password"`
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Username    string             `bson:"username"`
+	Email       string             `json:"email" bson:"email"`
+	Password    string             `json:"password" bson:"password"`
+	DisplayName string             `json:"displayName" bson:"displayName"`
+	Bio         string             `json:"bio" bson:"bio"`
+	AvatarURL   string             `json:"avatarUrl" bson:"avatarUrl"`
+	StatusText  string             `json:"statusText" bson:"statusText"`
+	// TimeZone is an IANA name such as "Europe/Berlin"
+	TimeZone string `json:"timeZone" bson:"timeZone"`
+	// HideLastSeen keeps the user's last-seen time private from other users
+	HideLastSeen bool `json:"hideLastSeen" bson:"hideLastSeen"`
+	// HideNotificationPreviews leaves message content out of push notifications
+	HideNotificationPreviews bool `json:"hideNotificationPreviews" bson:"hideNotificationPreviews"`
+	// EmailDigestsDisabled opts the user out of unread-message digests
+	EmailDigestsDisabled bool `json:"emailDigestsDisabled" bson:"emailDigestsDisabled"`
+	// MessagePermission is who may start a conversation with the user;
+	// empty means everyone
+	MessagePermission string `json:"messagePermission" bson:"messagePermission"`
+	// DeletionScheduledAt is when the account will be purged; logging in
+	// before then cancels the deletion
+	DeletionScheduledAt *time.Time `json:"deletionScheduledAt,omitempty" bson:"deletionScheduledAt,omitempty"`
+	// IsBot marks an assistant whose replies are generated; Bot holds its
+	// configuration. Clients see it through PublicProfile and the chat list
+	IsBot bool       `json:"-" bson:"isBot,omitempty"`
+	Bot   *BotConfig `json:"-" bson:"bot,omitempty"`
+	// BotManaged is set only on users created by EnsureBots, which never
+	// touches anyone else
+	BotManaged bool `json:"-" bson:"botManaged,omitempty"`
+	// IsAdmin grants the admin endpoints; it is only set in the database
+	IsAdmin bool `json:"-" bson:"isAdmin,omitempty"`
 }
 
 type Response struct {
@@ -19,6 +46,14 @@ type Response struct {
 	Data         interface{} `json:"data"`
 }
 
+// RegisterRequest is the body of a sign-up. Everything else about the user
+// is set later or by the server.
+type RegisterRequest struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
 type UserResponse struct {
 	ID       primitive.ObjectID `json:"id" bson:"_id,omitempty"`
 	Email    string             `json:"email" bson:"email"`
@@ -26,7 +61,25 @@ type UserResponse struct {
 	Username string             `json:"username" bson:"username"`
 }
 
-type BlacklistedToken struct {
-	Token     string    `bson:"token"`
-	ExpiredAt time.Time `bson:"expiredAt"`
+// PublicProfile is what other users may see about a user.
+type PublicProfile struct {
+	ID          primitive.ObjectID `json:"id"`
+	Username    string             `json:"username"`
+	DisplayName string             `json:"displayName"`
+	Bio         string             `json:"bio"`
+	AvatarURL   string             `json:"avatarUrl"`
+	StatusText  string             `json:"statusText"`
+	TimeZone    string             `json:"timeZone"`
+	IsBot       bool               `json:"isBot"`
+}
+
+// UpdateProfileRequest changes only the fields that are present. The avatar
+// is set from an attachment uploaded beforehand.
+type UpdateProfileRequest struct {
+	Username           *string `json:"username"`
+	DisplayName        *string `json:"displayName"`
+	Bio                *string `json:"bio"`
+	AvatarAttachmentID *string `json:"avatarAttachmentId"`
+	StatusText         *string `json:"statusText"`
+	TimeZone           *string `json:"timeZone"`
 }
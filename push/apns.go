@@ -0,0 +1,153 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// apnsTokenTTL is how long a provider token is reused. Apple rejects tokens
+// older than an hour.
+const apnsTokenTTL = 50 * time.Minute
+
+// APNsConfig holds the token-based authentication settings for APNs.
+type APNsConfig struct {
+	// KeyFile is the .p8 signing key downloaded from the developer account
+	KeyFile string
+	KeyID   string
+	TeamID  string
+	// Topic is the app's bundle ID
+	Topic      string
+	Production bool
+}
+
+// APNs sends notifications through the Apple Push Notification service.
+type APNs struct {
+	config APNsConfig
+	key    *ecdsa.PrivateKey
+	host   string
+	client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+func NewAPNs(config APNsConfig) (*APNs, error) {
+	pem, err := os.ReadFile(config.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+	if err != nil {
+		return nil, err
+	}
+
+	host := "https://api.sandbox.push.apple.com"
+	if config.Production {
+		host = "https://api.push.apple.com"
+	}
+
+	return &APNs{
+		config: config,
+		key:    key,
+		host:   host,
+		// APNs requires HTTP/2, which net/http negotiates over TLS
+		client: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// providerToken returns the signed JWT APNs expects, reusing it until it
+// nears expiry.
+func (a *APNs) providerToken() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && time.Since(a.issuedAt) < apnsTokenTTL {
+		return a.token, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.RegisteredClaims{
+		Issuer:   a.config.TeamID,
+		IssuedAt: jwt.NewNumericDate(now),
+	})
+	token.Header["kid"] = a.config.KeyID
+
+	signed, err := token.SignedString(a.key)
+	if err != nil {
+		return "", err
+	}
+	a.token = signed
+	a.issuedAt = now
+	return signed, nil
+}
+
+func (a *APNs) Send(ctx context.Context, token string, n Notification) error {
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": n.Title,
+				"body":  n.Body,
+			},
+			"sound":     "default",
+			"thread-id": n.CollapseKey,
+		},
+	}
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	bearer, err := a.providerToken()
+	if err != nil {
+		return fmt.Errorf("push: signing APNs token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.host+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("authorization", "bearer "+bearer)
+	req.Header.Set("apns-topic", a.config.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	if n.CollapseKey != "" {
+		req.Header.Set("apns-collapse-id", n.CollapseKey)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	var apnsErr struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&apnsErr)
+
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		apnsErr.Reason == "BadDeviceToken",
+		apnsErr.Reason == "Unregistered",
+		apnsErr.Reason == "DeviceTokenNotForTopic":
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("push: APNs returned %d: %s", resp.StatusCode, apnsErr.Reason)
+}
@@ -0,0 +1,49 @@
+package push
+
+import (
+	"context"
+	"sync"
+)
+
+// SentNotification is a notification recorded by the fake provider.
+type SentNotification struct {
+	Token        string
+	Notification Notification
+}
+
+// Fake is an in-process provider that records what it sends. Tokens marked
+// invalid fail with ErrInvalidToken, like a real provider would report them.
+type Fake struct {
+	mu      sync.Mutex
+	sent    []SentNotification
+	invalid map[string]bool
+}
+
+func NewFake() *Fake {
+	return &Fake{invalid: map[string]bool{}}
+}
+
+func (f *Fake) Send(ctx context.Context, token string, n Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.invalid[token] {
+		return ErrInvalidToken
+	}
+	f.sent = append(f.sent, SentNotification{Token: token, Notification: n})
+	return nil
+}
+
+// MarkInvalid makes later sends to token fail with ErrInvalidToken.
+func (f *Fake) MarkInvalid(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.invalid[token] = true
+}
+
+// Sent returns a copy of every notification sent so far.
+func (f *Fake) Sent() []SentNotification {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]SentNotification(nil), f.sent...)
+}
@@ -0,0 +1,133 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"cloud.google.com/go/auth"
+	"cloud.google.com/go/auth/credentials"
+)
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCM sends notifications through the Firebase Cloud Messaging HTTP v1 API.
+type FCM struct {
+	projectID string
+	creds     *auth.Credentials
+	client    *http.Client
+}
+
+// NewFCM creates an FCM provider authenticated with a service account key
+// file. The project ID is read from the key file when empty.
+func NewFCM(projectID, credentialsFile string) (*FCM, error) {
+	creds, err := credentials.DetectDefault(&credentials.DetectOptions{
+		Scopes:          []string{fcmScope},
+		CredentialsFile: credentialsFile,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if projectID == "" {
+		projectID, err = creds.ProjectID(context.Background())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &FCM{
+		projectID: projectID,
+		creds:     creds,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *fcmAndroid       `json:"android,omitempty"`
+	Webpush      *fcmWebpush       `json:"webpush,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmAndroid struct {
+	CollapseKey string `json:"collapse_key,omitempty"`
+}
+
+type fcmWebpush struct {
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+type fcmError struct {
+	Error struct {
+		Status  string `json:"status"`
+		Message string `json:"message"`
+		Details []struct {
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+func (f *FCM) Send(ctx context.Context, token string, n Notification) error {
+	msg := fcmMessage{
+		Token:        token,
+		Notification: fcmNotification{Title: n.Title, Body: n.Body},
+		Data:         n.Data,
+	}
+	if n.CollapseKey != "" {
+		msg.Android = &fcmAndroid{CollapseKey: n.CollapseKey}
+		msg.Webpush = &fcmWebpush{Headers: map[string]string{"Topic": n.CollapseKey}}
+	}
+
+	body, err := json.Marshal(map[string]interface{}{"message": msg})
+	if err != nil {
+		return err
+	}
+
+	accessToken, err := f.creds.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("push: fetching FCM access token: %w", err)
+	}
+
+	url := "https://fcm.googleapis.com/v1/projects/" + f.projectID + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken.Value)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	var fcmErr fcmError
+	json.Unmarshal(respBody, &fcmErr)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrInvalidToken
+	}
+	for _, detail := range fcmErr.Error.Details {
+		if detail.ErrorCode == "UNREGISTERED" {
+			return ErrInvalidToken
+		}
+	}
+	return fmt.Errorf("push: FCM returned %d: %s", resp.StatusCode, fcmErr.Error.Message)
+}
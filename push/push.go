@@ -0,0 +1,105 @@
+// Package push delivers notifications to devices that have no realtime
+// connection open, through FCM for Android and web and APNs for iOS.
+package push
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+)
+
+// Device platforms a token can be registered for
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+)
+
+// ErrInvalidToken is returned when the provider reports that a device token
+// is no longer valid and should be removed.
+var ErrInvalidToken = errors.New("push: device token is no longer valid")
+
+// ErrNoProvider is returned when no provider is configured for a platform.
+var ErrNoProvider = errors.New("push: no provider configured for platform")
+
+// Notification is a provider-independent push notification.
+type Notification struct {
+	Title string
+	Body  string
+	// CollapseKey lets the provider replace an undelivered notification
+	// with a newer one for the same key
+	CollapseKey string
+	Data        map[string]string
+}
+
+// Provider sends notifications to device tokens of one push service.
+type Provider interface {
+	Send(ctx context.Context, token string, n Notification) error
+}
+
+// providers maps each platform to the provider that serves it
+var providers = map[string]Provider{}
+
+// Init configures providers from the environment. Setting PUSH_PROVIDER=fake
+// routes every platform to an in-process fake instead.
+func Init() {
+	if os.Getenv("PUSH_PROVIDER") == "fake" {
+		fake := NewFake()
+		for _, platform := range []string{PlatformAndroid, PlatformIOS, PlatformWeb} {
+			providers[platform] = fake
+		}
+		log.Println("[INFO] Push notifications using fake provider")
+		return
+	}
+
+	if file := os.Getenv("FCM_CREDENTIALS_FILE"); file != "" {
+		fcm, err := NewFCM(os.Getenv("FCM_PROJECT_ID"), file)
+		if err != nil {
+			log.Printf("[ERROR] Failed to initialise FCM: %v", err)
+		} else {
+			providers[PlatformAndroid] = fcm
+			providers[PlatformWeb] = fcm
+			log.Println("[INFO] FCM push provider initialized")
+		}
+	}
+
+	if keyFile := os.Getenv("APNS_KEY_FILE"); keyFile != "" {
+		apns, err := NewAPNs(APNsConfig{
+			KeyFile:    keyFile,
+			KeyID:      os.Getenv("APNS_KEY_ID"),
+			TeamID:     os.Getenv("APNS_TEAM_ID"),
+			Topic:      os.Getenv("APNS_TOPIC"),
+			Production: os.Getenv("APNS_PRODUCTION") == "true",
+		})
+		if err != nil {
+			log.Printf("[ERROR] Failed to initialise APNs: %v", err)
+		} else {
+			providers[PlatformIOS] = apns
+			log.Println("[INFO] APNs push provider initialized")
+		}
+	}
+}
+
+// SetProvider overrides the provider for a platform.
+func SetProvider(platform string, p Provider) {
+	providers[platform] = p
+}
+
+// ValidPlatform reports whether platform is one devices can register for.
+func ValidPlatform(platform string) bool {
+	switch platform {
+	case PlatformAndroid, PlatformIOS, PlatformWeb:
+		return true
+	}
+	return false
+}
+
+// Send delivers a notification to a device token on the given platform.
+func Send(ctx context.Context, platform, token string, n Notification) error {
+	p, ok := providers[platform]
+	if !ok {
+		return ErrNoProvider
+	}
+	return p.Send(ctx, token, n)
+}
@@ -0,0 +1,49 @@
+package push
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSend(t *testing.T) {
+	fake := NewFake()
+	SetProvider(PlatformAndroid, fake)
+	defer delete(providers, PlatformAndroid)
+	fake.MarkInvalid("stale")
+
+	ctx := context.Background()
+	n := Notification{Title: "Sam", Body: "Hi", CollapseKey: "a-b"}
+	tests := []struct {
+		platform string
+		token    string
+		want     error
+	}{
+		{PlatformAndroid, "device", nil},
+		{PlatformAndroid, "stale", ErrInvalidToken},
+		{PlatformIOS, "device", ErrNoProvider},
+	}
+	for _, tt := range tests {
+		if err := Send(ctx, tt.platform, tt.token, n); err != tt.want {
+			t.Errorf("Send(%s, %s) error = %v, want %v", tt.platform, tt.token, err, tt.want)
+		}
+	}
+
+	sent := fake.Sent()
+	if len(sent) != 1 || sent[0].Token != "device" || sent[0].Notification.Body != "Hi" {
+		t.Errorf("sent %+v, want the one notification to device", sent)
+	}
+}
+
+func TestValidPlatform(t *testing.T) {
+	for platform, want := range map[string]bool{
+		PlatformAndroid: true,
+		PlatformIOS:     true,
+		PlatformWeb:     true,
+		"":              false,
+		"windows":       false,
+	} {
+		if got := ValidPlatform(platform); got != want {
+			t.Errorf("ValidPlatform(%q) = %v, want %v", platform, got, want)
+		}
+	}
+}
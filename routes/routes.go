@@ -12,25 +12,89 @@ func SetupRouter() *gin.Engine {
 	// Public routes
 	r.POST("/register", controllers.Register)
 	r.POST("/login", controllers.Login)
-	r.POST("/pusher/auth", controllers.PusherAuth)
+
+	// Pusher channel authorisation needs the caller's identity
+	r.POST("/pusher/auth", utils.JWTAuthMiddleware(), controllers.PusherAuth)
+	// Pusher webhooks are signed with the app secret instead of a JWT
+	r.POST("/pusher/webhook", controllers.PusherWebhook)
+	// Linked from digest emails, authorised by a signed token
+	r.GET("/unsubscribe/digest", controllers.UnsubscribeDigest)
+	// Linked from email change emails, authorised by single-use tokens
+	r.GET("/email/verify", controllers.VerifyEmailChange)
+	r.GET("/email/revert", controllers.RevertEmailChange)
+
+	// Uploaded attachments such as avatars, only to signed-in users
+	r.GET("/uploads/:name", utils.JWTAuthMiddleware(), controllers.ServeAttachment)
 
 	// Protected routes
 	auth := r.Group("/api")
 	auth.Use(utils.JWTAuthMiddleware())
 	{
-		// auth.GET("/profile", controllers.Profile) // Example
+		// Session routes
+		auth.POST("/logout", controllers.Logout)
+
+		// Profile routes
+		auth.GET("/me", controllers.GetMe)
+		auth.PATCH("/me", controllers.UpdateMe)
+		auth.DELETE("/me", controllers.DeleteAccount)
+		auth.POST("/me/export", controllers.ExportData)
+		auth.POST("/me/password", controllers.ChangePassword)
+		auth.POST("/me/email", controllers.ChangeEmail)
+		auth.POST("/attachments", controllers.UploadAttachment)
+
 		auth.GET("/users", controllers.GetUsers) // To be created
+		auth.GET("/users/blocked", controllers.GetBlockedUsers)
+		auth.GET("/users/:id", controllers.GetUserProfile)
+		auth.POST("/users/:id/block", controllers.BlockUser)
+		auth.DELETE("/users/:id/block", controllers.UnblockUser)
+		auth.POST("/users/:id/report", controllers.ReportUser)
 
 		// Chat routes
 		auth.GET("/chats", controllers.GetChatList)
 		auth.GET("/chat", controllers.GetChatByID)
+		auth.GET("/message-requests", controllers.GetMessageRequests)
+
+		// Contact routes
+		auth.GET("/contacts", controllers.GetContacts)
+		auth.GET("/contacts/requests", controllers.GetContactRequests)
+		auth.PUT("/contacts/settings", controllers.UpdateContactSettings)
+		auth.POST("/contacts/:id", controllers.SendContactRequest)
+		auth.POST("/contacts/:id/accept", controllers.AcceptContact)
+		auth.POST("/contacts/:id/decline", controllers.DeclineContact)
+		auth.DELETE("/contacts/:id", controllers.RemoveContact)
 
 		// Message routes
 		auth.POST("/send-message", controllers.SendMessage)
 		auth.GET("/messages/:userId", controllers.GetMessages)
+		auth.DELETE("/messages/:userId", controllers.DeleteChatHistory)
 		auth.POST("/messages/markseen/:userId", controllers.MarkMessagesSeen)
+		auth.POST("/messages/ack", controllers.AckMessages)
+		auth.POST("/messages/:id/translate", controllers.TranslateMessage)
 		auth.POST("/suggestions", controllers.GetReplySuggestions)
+		auth.POST("/suggestions/stream", controllers.StreamReplySuggestions)
+
+		// Conversation routes
+		auth.POST("/conversations/:id/typing", controllers.SetTyping)
+		auth.GET("/conversations/:id/read-pointers", controllers.GetReadPointers)
+		auth.PATCH("/conversations/:id/settings", controllers.UpdateConversationSettings)
+		auth.POST("/conversations/:id/summary", controllers.SummarizeConversation)
+
+		// Search routes
+		auth.GET("/search/messages", controllers.SearchMessages)
+		auth.GET("/search/semantic", controllers.SemanticSearch)
+
+		// Presence routes
+		auth.POST("/presence/heartbeat", controllers.PresenceHeartbeat)
+		auth.PUT("/presence/settings", controllers.UpdatePresenceSettings)
+
+		// Push notification routes
+		auth.POST("/devices", controllers.RegisterDevice)
+		auth.DELETE("/devices/:token", controllers.UnregisterDevice)
+		auth.PUT("/notifications/settings", controllers.UpdateNotificationSettings)
 
+		// Admin routes
+		admin := auth.Group("/admin", utils.AdminMiddleware())
+		admin.GET("/ai-usage", controllers.GetAIUsageReport)
 	}
 
 	return r
@@ -0,0 +1,31 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteChatHistoryRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := SetupRouter()
+
+	var handler string
+	for _, route := range r.Routes() {
+		if route.Method == http.MethodDelete && route.Path == "/api/messages/:userId" {
+			handler = route.Handler
+		}
+	}
+	if !strings.HasSuffix(handler, "controllers.DeleteChatHistory") {
+		t.Fatalf("DELETE /api/messages/:userId handler = %q, want controllers.DeleteChatHistory", handler)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/messages/aaaaaaaaaaaaaaaaaaaaaaaa", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unauthenticated delete returned %d, want %d", w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// AdminMiddleware lets only users with isAdmin set through. It must run
+// after JWTAuthMiddleware.
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+
+		count, err := DB.Collection("users").CountDocuments(ctx, bson.M{
+			"_id":     ObjectIDFromHex(c.GetString("userID")),
+			"isAdmin": true,
+		})
+		if err != nil || count == 0 {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"context"
 	"net/http"
 	"strings"
 	"time"
@@ -13,15 +14,17 @@ import (
 var jwtKey = []byte("your_secret_key") // Should be in environment config
 
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID    string `json:"user_id"`
+	SessionID string `json:"sid"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT(userID string) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+func GenerateJWT(userID, sessionID string) (string, error) {
+	expirationTime := time.Now().Add(sessionTTL)
 
 	claims := &Claims{
-		UserID: userID,
+		UserID:    userID,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -57,9 +60,47 @@ func JWTAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// Tokens die with their session, e.g. after a password change
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Second)
+		defer cancel()
+		if !sessionActive(ctx, claims.UserID, claims.SessionID) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session has been revoked"})
+			c.Abort()
+			return
+		}
+
 		// ✅ Save user ID to context so it can be used in handlers
 		c.Set("userID", claims.UserID)
+		c.Set("sessionID", claims.SessionID)
 
 		c.Next()
 	}
 }
+
+// unsubscribeAudience marks tokens that may only be used to unsubscribe from
+// digest emails.
+const unsubscribeAudience = "digest-unsubscribe"
+
+// GenerateUnsubscribeToken signs a non-expiring token for the unsubscribe
+// link in digest emails.
+func GenerateUnsubscribeToken(userID string) (string, error) {
+	claims := jwt.RegisteredClaims{
+		Subject:  userID,
+		Audience: jwt.ClaimStrings{unsubscribeAudience},
+		IssuedAt: jwt.NewNumericDate(time.Now()),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtKey)
+}
+
+// ParseUnsubscribeToken returns the user ID an unsubscribe token was issued
+// for.
+func ParseUnsubscribeToken(tokenStr string) (string, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtKey, nil
+	}, jwt.WithAudience(unsubscribeAudience), jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return "", err
+	}
+	return claims.Subject, nil
+}
@@ -5,12 +5,17 @@ import (
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 var DB *mongo.Database
 
+// UsernameCollation compares usernames case-insensitively. Queries must use
+// it to hit the unique username index.
+var UsernameCollation = &options.Collation{Locale: "en", Strength: 2}
+
 func ConnectDB() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -30,4 +35,172 @@ func ConnectDB() {
 
 	DB = client.Database("chat_db")
 	log.Println("Successfully connected to MongoDB!")
+
+	if err := ensureIndexes(ctx); err != nil {
+		log.Fatal("Failed to create MongoDB indexes:", err)
+	}
+}
+
+func ensureIndexes(ctx context.Context) error {
+	indexes := map[string][]mongo.IndexModel{
+		"users": {
+			{
+				Keys: bson.D{{Key: "username", Value: 1}},
+				Options: options.Index().
+					SetUnique(true).
+					SetCollation(UsernameCollation).
+					SetPartialFilterExpression(bson.M{"username": bson.M{"$gt": ""}}),
+			},
+			{
+				Keys:    bson.D{{Key: "deletionScheduledAt", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			},
+		},
+		"sessions": {
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+			// Expired sessions are removed by MongoDB
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+		"email_changes": {
+			{Keys: bson.D{{Key: "verifyTokenHash", Value: 1}}},
+			{Keys: bson.D{{Key: "revertTokenHash", Value: 1}}},
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+		},
+		"attachments": {
+			{Keys: bson.D{{Key: "ownerId", Value: 1}}},
+			{Keys: bson.D{{Key: "path", Value: 1}}},
+		},
+		"messages": {
+			// A retried send must not create a second message
+			{
+				Keys: bson.D{{Key: "senderID", Value: 1}, {Key: "clientMessageId", Value: 1}},
+				Options: options.Index().
+					SetUnique(true).
+					SetPartialFilterExpression(bson.M{"clientMessageId": bson.M{"$exists": true}}),
+			},
+			// Finds who an attachment was sent to
+			{
+				Keys:    bson.D{{Key: "attachments._id", Value: 1}},
+				Options: options.Index().SetSparse(true),
+			},
+			// Finds messages still to be embedded, which have no embeddedAt
+			{Keys: bson.D{{Key: "embeddedAt", Value: 1}, {Key: "_id", Value: 1}}},
+			// Full-text search. Words aren't stemmed, since conversations
+			// can be in any language
+			{
+				Keys:    bson.D{{Key: "content", Value: "text"}},
+				Options: options.Index().SetDefaultLanguage("none"),
+			},
+		},
+		"device_tokens": {
+			// A token belongs to whichever user registered it last
+			{
+				Keys:    bson.D{{Key: "token", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "userId", Value: 1}}},
+		},
+		"blocks": {
+			{
+				Keys:    bson.D{{Key: "blockerId", Value: 1}, {Key: "blockedId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "blockedId", Value: 1}}},
+		},
+		"reports": {
+			{Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+		},
+		"contacts": {
+			{
+				Keys:    bson.D{{Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "addresseeId", Value: 1}, {Key: "status", Value: 1}}},
+			{Keys: bson.D{{Key: "requesterId", Value: 1}, {Key: "status", Value: 1}}},
+		},
+		"conversation_summaries": {
+			{
+				Keys:    bson.D{{Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			// Cached summaries are dropped after a week
+			{
+				Keys:    bson.D{{Key: "createdAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(7 * 24 * 60 * 60),
+			},
+		},
+		"conversation_settings": {
+			{
+				Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "peerId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+		},
+		"message_translations": {
+			{
+				Keys:    bson.D{{Key: "messageId", Value: 1}, {Key: "language", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			// Translations are redone on demand after 30 days
+			{
+				Keys:    bson.D{{Key: "createdAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(30 * 24 * 60 * 60),
+			},
+		},
+		"message_embeddings": {
+			{
+				Keys:    bson.D{{Key: "messageId", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "participants", Value: 1}}},
+		},
+		"ai_usage": {
+			{
+				Keys: bson.D{
+					{Key: "userId", Value: 1},
+					{Key: "day", Value: 1},
+					{Key: "feature", Value: 1},
+					{Key: "model", Value: 1},
+				},
+				Options: options.Index().SetUnique(true),
+			},
+			{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "month", Value: 1}}},
+			{Keys: bson.D{{Key: "day", Value: 1}}},
+		},
+		"ai_quota": {
+			{
+				Keys: bson.D{
+					{Key: "userId", Value: 1},
+					{Key: "feature", Value: 1},
+					{Key: "period", Value: 1},
+				},
+				Options: options.Index().SetUnique(true),
+			},
+			// Counters are removed once their period is over
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+		"ai_cache": {
+			{
+				Keys:    bson.D{{Key: "key", Value: 1}},
+				Options: options.Index().SetUnique(true),
+			},
+			// Entries are removed once they expire
+			{
+				Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+				Options: options.Index().SetExpireAfterSeconds(0),
+			},
+		},
+	}
+
+	for collection, models := range indexes {
+		if _, err := DB.Collection(collection).Indexes().CreateMany(ctx, models); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"os"
 
 	"github.com/pusher/pusher-http-go/v5"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 var PusherClient pusher.Client
@@ -20,3 +21,42 @@ func InitPusher() {
 
 	log.Println("[INFO] Pusher initialized successfully")
 }
+
+// UserChannel returns the private channel a user receives their own events on.
+func UserChannel(userID string) string {
+	return "private-chat-" + userID
+}
+
+// ConversationKey identifies the one-to-one conversation between two users,
+// independent of which of them is asking.
+func ConversationKey(a, b primitive.ObjectID) string {
+	if a.Hex() > b.Hex() {
+		a, b = b, a
+	}
+	return a.Hex() + "-" + b.Hex()
+}
+
+// ConversationChannel returns the presence channel shared by both members of a
+// conversation.
+func ConversationChannel(a, b primitive.ObjectID) string {
+	return "presence-conversation-" + ConversationKey(a, b)
+}
+
+// maxTriggerChannels is the most channels Pusher accepts in a single trigger.
+const maxTriggerChannels = 100
+
+// TriggerUsers sends an event to the personal channel of every given user.
+func TriggerUsers(userIDs []string, event string, data interface{}) error {
+	channels := make([]string, 0, len(userIDs))
+	for _, id := range userIDs {
+		channels = append(channels, UserChannel(id))
+	}
+
+	for start := 0; start < len(channels); start += maxTriggerChannels {
+		end := min(start+maxTriggerChannels, len(channels))
+		if err := PusherClient.TriggerMulti(channels[start:end], event, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/sajanIocod/chat_backend/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sessionTTL matches the lifetime of the tokens issued for a session.
+const sessionTTL = 24 * time.Hour
+
+// NewSessionToken starts a session for the user and returns its token.
+func NewSessionToken(userID primitive.ObjectID) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	session := models.Session{
+		ID:        primitive.NewObjectID(),
+		UserID:    userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(sessionTTL),
+	}
+	if _, err := DB.Collection("sessions").InsertOne(ctx, session); err != nil {
+		return "", err
+	}
+
+	return GenerateJWT(userID.Hex(), session.ID.Hex())
+}
+
+// RevokeSessions ends every session of the user except keepSessionID, which
+// may be empty to end them all.
+func RevokeSessions(ctx context.Context, userID primitive.ObjectID, keepSessionID string) error {
+	filter := bson.M{"userId": userID}
+	if keep, err := primitive.ObjectIDFromHex(keepSessionID); err == nil {
+		filter["_id"] = bson.M{"$ne": keep}
+	}
+	_, err := DB.Collection("sessions").DeleteMany(ctx, filter)
+	return err
+}
+
+// EndSession ends one of the user's sessions, e.g. on logout.
+func EndSession(ctx context.Context, userID primitive.ObjectID, sessionID string) error {
+	sid, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return err
+	}
+	_, err = DB.Collection("sessions").DeleteOne(ctx, bson.M{"_id": sid, "userId": userID})
+	return err
+}
+
+func sessionActive(ctx context.Context, userID, sessionID string) bool {
+	sid, err := primitive.ObjectIDFromHex(sessionID)
+	if err != nil {
+		return false
+	}
+	count, err := DB.Collection("sessions").CountDocuments(ctx, bson.M{
+		"_id":       sid,
+		"userId":    ObjectIDFromHex(userID),
+		"expiresAt": bson.M{"$gt": time.Now()},
+	})
+	return err == nil && count > 0
+}
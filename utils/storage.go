@@ -0,0 +1,11 @@
+package utils
+
+import "os"
+
+// UploadDir is where uploaded attachments are stored, set by UPLOAD_DIR.
+func UploadDir() string {
+	if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
@@ -0,0 +1,73 @@
+package vectors
+
+import (
+	"context"
+
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// atlasCandidates is how many nearest neighbours Atlas considers per result.
+const atlasCandidates = 20
+
+// Atlas searches with an Atlas vector search index on message_embeddings.
+// The index must cover "vector" with cosine similarity, and "participants"
+// and "model" as filter fields:
+//
+//	{"fields": [
+//	  {"type": "vector", "path": "vector", "numDimensions": 768, "similarity": "cosine"},
+//	  {"type": "filter", "path": "participants"},
+//	  {"type": "filter", "path": "model"}
+//	]}
+type Atlas struct {
+	name string
+}
+
+// Add does nothing; Atlas indexes stored entries itself.
+func (a *Atlas) Add(entries []Entry) {}
+
+// RemoveUser does nothing; Atlas follows the collection.
+func (a *Atlas) RemoveUser(userID primitive.ObjectID) {}
+
+// ReplaceUser does nothing; Atlas follows the collection.
+func (a *Atlas) ReplaceUser(userID, replacement primitive.ObjectID) {}
+
+func (a *Atlas) Search(ctx context.Context, vector []float32, model string, userID primitive.ObjectID, limit int) ([]Match, error) {
+	pipeline := []bson.M{
+		{
+			"$vectorSearch": bson.M{
+				"index":         a.name,
+				"path":          "vector",
+				"queryVector":   vector,
+				"numCandidates": limit * atlasCandidates,
+				"limit":         limit,
+				"filter": bson.M{
+					"participants": userID,
+					"model":        model,
+				},
+			},
+		},
+		{
+			"$project": bson.M{
+				"_id":       0,
+				"messageId": 1,
+				"score":     bson.M{"$meta": "vectorSearchScore"},
+			},
+		},
+	}
+
+	cursor, err := utils.DB.Collection("message_embeddings").Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var matches []Match
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+	// Atlas scales cosine similarity to 0..1
+	for i := range matches {
+		matches[i].Score = matches[i].Score*2 - 1
+	}
+	return matches, nil
+}
@@ -0,0 +1,127 @@
+package vectors
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Local keeps every embedding in memory and compares the query with each of
+// the user's. It is meant for development, not large histories, and only
+// sees entries stored by this process or present when it was loaded.
+type Local struct {
+	mu sync.RWMutex
+	// byUser holds each participant's entries, keyed by message ID
+	byUser map[primitive.ObjectID]map[primitive.ObjectID]*Entry
+}
+
+func NewLocal() *Local {
+	return &Local{byUser: map[primitive.ObjectID]map[primitive.ObjectID]*Entry{}}
+}
+
+// Load adds every stored embedding to the index.
+func (l *Local) Load() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	cursor, err := utils.DB.Collection("message_embeddings").Find(ctx, bson.M{})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	var batch []Entry
+	for cursor.Next(ctx) {
+		var entry Entry
+		if err := cursor.Decode(&entry); err != nil {
+			return err
+		}
+		batch = append(batch, entry)
+		if len(batch) == 1000 {
+			l.Add(batch)
+			batch = nil
+		}
+	}
+	l.Add(batch)
+	return cursor.Err()
+}
+
+func (l *Local) Add(entries []Entry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range entries {
+		entry := entries[i]
+		for _, userID := range entry.Participants {
+			if l.byUser[userID] == nil {
+				l.byUser[userID] = map[primitive.ObjectID]*Entry{}
+			}
+			l.byUser[userID][entry.MessageID] = &entry
+		}
+	}
+}
+
+func (l *Local) RemoveUser(userID primitive.ObjectID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for messageID, entry := range l.byUser[userID] {
+		for _, participant := range entry.Participants {
+			delete(l.byUser[participant], messageID)
+		}
+	}
+	delete(l.byUser, userID)
+}
+
+func (l *Local) ReplaceUser(userID, replacement primitive.ObjectID) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entries := l.byUser[userID]
+	if len(entries) == 0 {
+		return
+	}
+	delete(l.byUser, userID)
+	if l.byUser[replacement] == nil {
+		l.byUser[replacement] = map[primitive.ObjectID]*Entry{}
+	}
+	for messageID, entry := range entries {
+		for i, participant := range entry.Participants {
+			if participant == userID {
+				entry.Participants[i] = replacement
+			}
+		}
+		l.byUser[replacement][messageID] = entry
+	}
+}
+
+func (l *Local) Search(ctx context.Context, vector []float32, model string, userID primitive.ObjectID, limit int) ([]Match, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var matches []Match
+	for _, entry := range l.byUser[userID] {
+		if entry.Model != model || len(entry.Vector) != len(vector) {
+			continue
+		}
+		// Vectors are normalised, so the dot product is the cosine
+		var score float64
+		for i, v := range entry.Vector {
+			score += float64(v) * float64(vector[i])
+		}
+		matches = append(matches, Match{MessageID: entry.MessageID, Score: score})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, nil
+}
@@ -0,0 +1,134 @@
+// Package vectors stores message embeddings and finds the ones nearest a
+// query. Vectors are always kept in the message_embeddings collection; they
+// are searched either by a MongoDB Atlas vector index or, for development,
+// by an in-process index loaded from that collection at startup.
+package vectors
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	"github.com/sajanIocod/chat_backend/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Entry is the embedding of one message.
+type Entry struct {
+	MessageID primitive.ObjectID `bson:"messageId"`
+	// Participants are the sender and receiver, whose searches may find it
+	Participants []primitive.ObjectID `bson:"participants"`
+	// Model produced the vector; only vectors of one model are comparable
+	Model     string    `bson:"model"`
+	Vector    []float32 `bson:"vector"`
+	CreatedAt time.Time `bson:"createdAt"`
+}
+
+// Match is a message found by a search, with its cosine similarity to the
+// query.
+type Match struct {
+	MessageID primitive.ObjectID `bson:"messageId"`
+	Score     float64            `bson:"score"`
+}
+
+// Index searches stored embeddings.
+type Index interface {
+	// Add makes entries searchable once they are stored.
+	Add(entries []Entry)
+	// RemoveUser stops the entries of messages userID took part in from
+	// being found, once they are deleted.
+	RemoveUser(userID primitive.ObjectID)
+	// ReplaceUser makes the entries userID took part in searchable by
+	// replacement instead, once they are updated.
+	ReplaceUser(userID, replacement primitive.ObjectID)
+	// Search returns up to limit messages visible to userID whose vectors,
+	// made by model, are nearest to vector, best first.
+	Search(ctx context.Context, vector []float32, model string, userID primitive.ObjectID, limit int) ([]Match, error)
+}
+
+var index Index
+
+// Init picks the index from VECTOR_INDEX: "atlas" for an Atlas vector search
+// index named by ATLAS_VECTOR_INDEX (default "message_embeddings"), or
+// "local" (the default) for the in-process index.
+func Init() {
+	switch os.Getenv("VECTOR_INDEX") {
+	case "atlas":
+		name := os.Getenv("ATLAS_VECTOR_INDEX")
+		if name == "" {
+			name = "message_embeddings"
+		}
+		index = &Atlas{name: name}
+		log.Printf("[INFO] Vector search using Atlas index %s", name)
+	default:
+		local := NewLocal()
+		if err := local.Load(); err != nil {
+			log.Printf("[ERROR] Failed to load message embeddings: %v", err)
+		}
+		index = local
+		log.Printf("[INFO] Vector search using the local index")
+	}
+}
+
+// Store saves the entries and adds them to the index.
+func Store(ctx context.Context, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	models := make([]mongo.WriteModel, 0, len(entries))
+	for _, entry := range entries {
+		models = append(models, mongo.NewReplaceOneModel().
+			SetFilter(bson.M{"messageId": entry.MessageID}).
+			SetReplacement(entry).
+			SetUpsert(true))
+	}
+	_, err := utils.DB.Collection("message_embeddings").BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return err
+	}
+	if index != nil {
+		index.Add(entries)
+	}
+	return nil
+}
+
+// DeleteUser deletes the embeddings of every message userID took part in.
+func DeleteUser(ctx context.Context, userID primitive.ObjectID) error {
+	_, err := utils.DB.Collection("message_embeddings").DeleteMany(ctx, bson.M{"participants": userID})
+	if err != nil {
+		return err
+	}
+	if index != nil {
+		index.RemoveUser(userID)
+	}
+	return nil
+}
+
+// ReplaceUser moves the embeddings of messages userID took part in to
+// replacement, as when their messages are anonymised.
+func ReplaceUser(ctx context.Context, userID, replacement primitive.ObjectID) error {
+	_, err := utils.DB.Collection("message_embeddings").UpdateMany(ctx,
+		bson.M{"participants": userID},
+		bson.M{"$set": bson.M{"participants.$": replacement}},
+	)
+	if err != nil {
+		return err
+	}
+	if index != nil {
+		index.ReplaceUser(userID, replacement)
+	}
+	return nil
+}
+
+// Search finds the messages nearest to vector among those userID took part
+// in.
+func Search(ctx context.Context, vector []float32, model string, userID primitive.ObjectID, limit int) ([]Match, error) {
+	if index == nil {
+		return nil, nil
+	}
+	return index.Search(ctx, vector, model, userID, limit)
+}